@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// BenchResult the measures of running a program many times
+type BenchResult struct {
+	runs    int
+	steps   int
+	elapsed time.Duration
+	allocs  uint64
+	bytes   uint64
+}
+
+// executeSteps execute the program counting the instructions executed, at most `maxSteps` when it isn't 0
+func executeSteps(prog Program, input []int64, maxSteps int) ([]WriteResult, int, error) {
+	vm := newVM(prog, input)
+	vm.maxSteps = maxSteps
+	for !vm.done() {
+		if err := vm.step(); err != nil {
+			return vm.results, vm.steps, err
+		}
+	}
+	return vm.results, vm.steps, nil
+}
+
+// benchmark execute the program `runs` times with the engine, the steps are the same in every run
+func benchmark(engine string, prog Program, input []int64, runs int, maxSteps int) (BenchResult, error) {
+	_, steps, err := executeSteps(prog, input, maxSteps)
+	if err != nil {
+		return BenchResult{}, err
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for i := 0; i < runs; i++ {
+		if _, err := executeWith(engine, prog, input, maxSteps); err != nil {
+			return BenchResult{}, err
+		}
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	return BenchResult{
+		runs:    runs,
+		steps:   steps,
+		elapsed: elapsed,
+		allocs:  after.Mallocs - before.Mallocs,
+		bytes:   after.TotalAlloc - before.TotalAlloc,
+	}, nil
+}
+
+// perSecond the instructions executed per second
+func (r BenchResult) perSecond() float64 {
+	if r.elapsed <= 0 {
+		return 0
+	}
+	return float64(r.steps) * float64(r.runs) / r.elapsed.Seconds()
+}
+
+// runBench the `bench` command, measure how fast the program executes
+func runBench(args []string) int {
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	runs := flags.Int("n", 100, i18n.Sprintf(I18N_BENCH_FLAG_RUNS))
+	optimized := flags.Bool("O", false, i18n.Sprintf(I18N_FLAG_OPTIMIZE))
+	engine := flags.String("engine", ENGINE_SWITCH, i18n.Sprintf(I18N_FLAG_ENGINE))
+	noAssert := flags.Bool("no-assert", false, i18n.Sprintf(I18N_FLAG_NO_ASSERT))
+	maxSteps := flags.Int("max-steps", GOLDEN_MAX_STEPS, i18n.Sprintf(I18N_FLAG_MAX_STEPS))
+	flags.Parse(args)
+
+	if flags.NArg() == 0 || flags.NArg() > 2 {
+		i18n.Println(I18N_ERR_PROG_TOO_MANY_PARMS)
+		return 1
+	}
+	if !isProgramFile(flags.Arg(0)) {
+		i18n.Println(I18N_ERR_PROG_NEED_ASM_EXT)
+		return 1
+	}
+	if *engine != ENGINE_SWITCH && *engine != ENGINE_CLOSURE {
+		i18n.Printf(I18N_ERR_ENGINE_NOT_FOUND, *engine)
+		return 1
+	}
+	if *runs < 1 {
+		*runs = 1
+	}
+
+	prog, err := loadProgram(flags.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if *noAssert {
+		*prog = removeAsserts(*prog)
+	}
+	if *optimized {
+		*prog = optimize(*prog)
+	}
+	input, err := readInput(flags.Arg(1))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	res, err := benchmark(*engine, *prog, input, *runs, *maxSteps)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	i18n.Printf(I18N_BENCH_RUNS, res.runs, *engine, res.elapsed)
+	i18n.Printf(I18N_BENCH_STEPS, res.steps, res.steps*res.runs)
+	i18n.Printf(I18N_BENCH_SPEED, res.perSecond())
+	i18n.Printf(I18N_BENCH_ALLOCS, res.allocs/uint64(res.runs), res.bytes/uint64(res.runs))
+	return 0
+}
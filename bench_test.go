@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// BENCH_PROGRAMS representative programs, each one stresses a different part of the VM
+var BENCH_PROGRAMS = map[string]string{
+	// a counter and a sum, only `$` values
+	"loop": `$0 = 0
+$1 = 0
+loop:
+  $1 = $1 + 1
+  $0 = $0 + $1
+  to loop if $1 < 10000
+write $0
+`,
+	// fill an array through a pointer and read it back
+	"indirection": `$0 = 100
+$1 = 0
+fill:
+  &0 = $1 * 3
+  $0 = $0 + 1
+  $1 = $1 + 1
+  to fill if $1 < 500
+$0 = 100
+$2 = 0
+sum:
+  $2 = $2 + &0
+  $0 = $0 + 1
+  to sum if $0 < 600
+write $2
+`,
+	// every comparison of a long condition is evaluated by `executeIf`
+	"conditions": `$0 = 0
+loop:
+  $0 = $0 + 1
+  to loop if $0 < 10000 && $0 != -1 || $0 == -2 && $0 >= 0 || $0 <= -3
+write $0
+`,
+}
+
+// benchSource a long source with every kind of instruction, to measure `compile`
+func benchSource(n int) string {
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		sb.WriteString(fmt.Sprintf("label%d:\n", i))
+		sb.WriteString("  $1 = $2 + 3 # sum\n")
+		sb.WriteString("  &4 = $5 / &6\n")
+		sb.WriteString(fmt.Sprintf("  to label%d if $1 < 10 && &2 >= -3 || $3 != 4\n", i))
+		sb.WriteString(fmt.Sprintf("  read $7 label%d\n", i))
+		sb.WriteString("  write &8\n")
+	}
+	return sb.String()
+}
+
+func BenchmarkCompile(b *testing.B) {
+	code := benchSource(200)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := compile(code); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkExecute(b *testing.B) {
+	for _, name := range []string{"loop", "indirection", "conditions"} {
+		prog, err := compile(BENCH_PROGRAMS[name])
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, engine := range []string{ENGINE_SWITCH, ENGINE_CLOSURE} {
+			b.Run(name+"/"+engine, func(b *testing.B) {
+				_, steps, err := executeSteps(*prog, nil, 0)
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.ReportAllocs()
+				b.ResetTimer()
+				start := time.Now()
+				for i := 0; i < b.N; i++ {
+					if _, err := executeWith(engine, *prog, nil, 0); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(steps)*float64(b.N)/time.Since(start).Seconds(), "inst/s")
+			})
+		}
+	}
+}
+
+func TestBenchmark(t *testing.T) {
+	prog, err := compile(BENCH_PROGRAMS["loop"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, engine := range []string{ENGINE_SWITCH, ENGINE_CLOSURE} {
+		res, err := benchmark(engine, *prog, nil, 3, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.runs != 3 || res.steps != 2+3*10000+1 {
+			t.Errorf("%s\nExpected: '%v'\nReceived: '%v'", engine, fmt.Sprint(3, " ", 2+3*10000+1), fmt.Sprint(res.runs, " ", res.steps))
+		}
+	}
+}
+
+func TestBenchmarkLimit(t *testing.T) {
+	prog, err := compile("aa:\nto aa\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "[Execution error: line 2] <[limit]> too many instructions executed, the limit is: 100."
+	for _, engine := range []string{ENGINE_SWITCH, ENGINE_CLOSURE} {
+		if _, err := benchmark(engine, *prog, nil, 1, 100); err == nil || err.Error() != expected {
+			t.Errorf("%s\nExpected: '%v'\nReceived: '%v'", engine, expected, err)
+		}
+	}
+}
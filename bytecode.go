@@ -0,0 +1,506 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// The bytecode is little endian and has the sections:
+//
+//	header       magic "FBC\x00", version uint16
+//	constants    count uint32, then each constant int64
+//	labels       count uint32, then each label name (uint16 length + bytes) and instruction index uint32
+//	line table   count uint32, then the source line of each instruction uint32
+//	instructions count uint32, then each instruction type uint8 followed by its operands
+//
+// A value is its type uint8 followed by the constant index uint32 or the memory slot uint16.
+// A condition is its size uint16 followed by the values and the operators uint8, the version 2
+// added the assertion, a condition followed by the message (uint16 length + bytes). The version 3 added
+// the syscall, its name (uint16 length + bytes) followed by the count uint16 and the values of the arguments.
+const (
+	BYTECODE_MAGIC   = "FBC\x00"
+	BYTECODE_VERSION = 3
+	BYTECODE_EXT     = ".fbc"
+
+	BYTECODE_NO_LABEL = 0xFFFFFFFF
+)
+
+type bytecodeWriter struct {
+	buf    bytes.Buffer
+	consts map[int64]uint32
+	labels map[string]uint32
+}
+
+func (w *bytecodeWriter) write(data interface{}) {
+	binary.Write(&w.buf, binary.LittleEndian, data)
+}
+
+func (w *bytecodeWriter) writeValue(v InstValue) {
+	w.write(uint8(v.typ))
+	if v.typ == VAL_CONST {
+		w.write(w.consts[v.val])
+	} else {
+		w.write(uint16(v.val))
+	}
+}
+
+func (w *bytecodeWriter) writeCondition(cond []interface{}) {
+	w.write(uint16(len(cond)))
+	for j, p := range cond {
+		if ifInstOrder(j) == IFO_VAL {
+			w.writeValue(p.(InstValue))
+		} else {
+			w.write(uint8(p.(int64)))
+		}
+	}
+}
+
+func (w *bytecodeWriter) writeLabel(label string) {
+	if label == "" {
+		w.write(uint32(BYTECODE_NO_LABEL))
+	} else {
+		w.write(w.labels[label])
+	}
+}
+
+// programConstants every constant used by the program in the order they appear
+func programConstants(prog Program) []int64 {
+	var consts []int64
+	seen := make(map[int64]bool)
+	add := func(v InstValue) {
+		if v.typ == VAL_CONST && !seen[v.val] {
+			seen[v.val] = true
+			consts = append(consts, v.val)
+		}
+	}
+	for _, inst := range prog.instructions {
+		switch inst.typ {
+		case INST_OP:
+			op := inst.val.(Operation)
+			add(op.v)
+			add(op.v1)
+			add(op.v2)
+			break
+		case INST_TO:
+			for i, p := range inst.val.(IfInst).moveIf {
+				if ifInstOrder(i) == IFO_VAL {
+					add(p.(InstValue))
+				}
+			}
+			break
+		case INST_ASSERT:
+			for i, p := range inst.val.(AssertInst).cond {
+				if ifInstOrder(i) == IFO_VAL {
+					add(p.(InstValue))
+				}
+			}
+			break
+		case INST_WRITE:
+			add(inst.val.(InstValue))
+			break
+		case INST_READ:
+			add(inst.val.(ReadInst).target)
+			break
+		case INST_SYS:
+			for _, arg := range inst.val.(SysInst).args {
+				add(arg)
+			}
+			break
+		}
+	}
+	return consts
+}
+
+// sortedLabels the label names sorted so the encoding is always the same
+func sortedLabels(prog Program) []string {
+	names := make([]string, 0, len(prog.labels))
+	for name := range prog.labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// encodeProgram serialize the program into bytecode
+func encodeProgram(prog Program) []byte {
+	w := bytecodeWriter{consts: make(map[int64]uint32), labels: make(map[string]uint32)}
+	w.buf.WriteString(BYTECODE_MAGIC)
+	w.write(uint16(BYTECODE_VERSION))
+
+	consts := programConstants(prog)
+	w.write(uint32(len(consts)))
+	for i, c := range consts {
+		w.consts[c] = uint32(i)
+		w.write(c)
+	}
+
+	names := sortedLabels(prog)
+	w.write(uint32(len(names)))
+	for i, name := range names {
+		w.labels[name] = uint32(i)
+		w.write(uint16(len(name)))
+		w.buf.WriteString(name)
+		w.write(uint32(prog.labels[name]))
+	}
+
+	w.write(uint32(len(prog.instructions)))
+	for _, inst := range prog.instructions {
+		w.write(uint32(inst.line))
+	}
+
+	w.write(uint32(len(prog.instructions)))
+	for _, inst := range prog.instructions {
+		w.write(uint8(inst.typ))
+		switch inst.typ {
+		case INST_OP:
+			op := inst.val.(Operation)
+			w.write(uint8(op.op))
+			w.writeValue(op.v)
+			w.writeValue(op.v1)
+			w.writeValue(op.v2)
+			break
+		case INST_TO:
+			i := inst.val.(IfInst)
+			w.writeLabel(i.target)
+			w.writeCondition(i.moveIf)
+			break
+		case INST_WRITE:
+			w.writeValue(inst.val.(InstValue))
+			break
+		case INST_READ:
+			r := inst.val.(ReadInst)
+			w.writeValue(r.target)
+			w.writeLabel(r.elseLabel)
+			break
+		case INST_ASSERT:
+			a := inst.val.(AssertInst)
+			w.writeCondition(a.cond)
+			w.write(uint16(len(a.message)))
+			w.buf.WriteString(a.message)
+			break
+		case INST_SYS:
+			sys := inst.val.(SysInst)
+			w.write(uint16(len(sys.name)))
+			w.buf.WriteString(sys.name)
+			w.write(uint16(len(sys.args)))
+			for _, arg := range sys.args {
+				w.writeValue(arg)
+			}
+			break
+		}
+	}
+
+	return w.buf.Bytes()
+}
+
+type bytecodeReader struct {
+	r      *bytes.Reader
+	err    error
+	consts []int64
+	labels []string
+}
+
+func (r *bytecodeReader) read(data interface{}) {
+	if r.err == nil {
+		r.err = binary.Read(r.r, binary.LittleEndian, data)
+	}
+}
+
+func (r *bytecodeReader) invalid(problem interface{}) {
+	if r.err == nil {
+		r.err = formatError("bytecode", I18N_BYTECODE_ERR_INVALID, problem)
+	}
+}
+
+func (r *bytecodeReader) readByte() int64 {
+	var b uint8
+	r.read(&b)
+	return int64(b)
+}
+
+func (r *bytecodeReader) readValue() InstValue {
+	typ := r.readByte()
+	switch typ {
+	case VAL_CONST:
+		var i uint32
+		r.read(&i)
+		if int(i) >= len(r.consts) {
+			r.invalid(i)
+			return InstValue{}
+		}
+		return InstValue{typ: VAL_CONST, val: r.consts[i]}
+	case VAL_VAR, VAL_REF:
+		var slot uint16
+		r.read(&slot)
+		if slot >= MEMORY_SIZE {
+			r.invalid(slot)
+		}
+		return InstValue{typ: int(typ), val: int64(slot)}
+	}
+	r.invalid(typ)
+	return InstValue{}
+}
+
+func (r *bytecodeReader) readCondition() []interface{} {
+	var size uint16
+	r.read(&size)
+	if size > 0 && ifInstOrder(int(size)-1) != IFO_VAL {
+		r.invalid(size)
+	}
+	var cond []interface{}
+	for j := 0; j < int(size) && r.err == nil; j++ {
+		switch ifInstOrder(j) {
+		case IFO_VAL:
+			cond = append(cond, r.readValue())
+			break
+		case IFO_CMP:
+			cp := r.readByte()
+			if cp < COMP_EQ || cp > COMP_LE {
+				r.invalid(cp)
+			}
+			cond = append(cond, cp)
+			break
+		case IFO_LOP:
+			lop := r.readByte()
+			if lop < LOP_AND || lop > LOP_OR {
+				r.invalid(lop)
+			}
+			cond = append(cond, lop)
+			break
+		}
+	}
+	return cond
+}
+
+func (r *bytecodeReader) readString() string {
+	var size uint16
+	r.read(&size)
+	if r.err == nil && int(size) > r.r.Len() {
+		r.invalid(size)
+		return ""
+	}
+	text := make([]byte, size)
+	r.read(text)
+	return string(text)
+}
+
+func (r *bytecodeReader) readLabel(optional bool) string {
+	var i uint32
+	r.read(&i)
+	if i == BYTECODE_NO_LABEL && optional {
+		return ""
+	}
+	if int(i) >= len(r.labels) {
+		if r.err == nil {
+			r.err = formatError("bytecode", I18N_BYTECODE_ERR_JUMP, i)
+		}
+		return ""
+	}
+	return r.labels[i]
+}
+
+func (r *bytecodeReader) readCount() int {
+	var n uint32
+	r.read(&n)
+	if r.err == nil && int64(n) > int64(r.r.Len()) {
+		r.invalid(n)
+		return 0
+	}
+	return int(n)
+}
+
+// decodeProgram load and validate a program serialized by `encodeProgram`
+func decodeProgram(data []byte) (*Program, error) {
+	if !bytes.HasPrefix(data, []byte(BYTECODE_MAGIC)) {
+		return nil, formatError("bytecode", I18N_BYTECODE_ERR_MAGIC, BYTECODE_EXT)
+	}
+	r := bytecodeReader{r: bytes.NewReader(data[len(BYTECODE_MAGIC):])}
+
+	var version uint16
+	r.read(&version)
+	if r.err == nil && (version < 1 || version > BYTECODE_VERSION) {
+		return nil, formatError("bytecode", I18N_BYTECODE_ERR_VERSION, version)
+	}
+
+	r.consts = make([]int64, r.readCount())
+	for i := range r.consts {
+		r.read(&r.consts[i])
+	}
+
+	prog := Program{labels: make(map[string]int)}
+	var targets []uint32
+	r.labels = make([]string, r.readCount())
+	for i := range r.labels {
+		var size uint16
+		r.read(&size)
+		name := make([]byte, size)
+		r.read(name)
+		var target uint32
+		r.read(&target)
+		r.labels[i] = string(name)
+		prog.labels[r.labels[i]] = int(target)
+		targets = append(targets, target)
+		if r.err == nil && !isWord(r.labels[i]) {
+			r.invalid(r.labels[i])
+		}
+	}
+
+	lines := make([]uint32, r.readCount())
+	for i := range lines {
+		r.read(&lines[i])
+	}
+
+	n := r.readCount()
+	if r.err == nil && n != len(lines) {
+		r.invalid(n)
+	}
+	for i := 0; i < n && r.err == nil; i++ {
+		inst := Instruction{typ: int(r.readByte()), line: int(lines[i])}
+		switch inst.typ {
+		case INST_OP:
+			op := Operation{op: int(r.readByte())}
+			op.v = r.readValue()
+			op.v1 = r.readValue()
+			op.v2 = r.readValue()
+			if op.op < OP_UNI || op.op > OP_DIV {
+				r.invalid(op.op)
+			}
+			if op.v.typ == VAL_CONST {
+				r.invalid(op.v.val)
+			}
+			inst.val = op
+			break
+		case INST_TO:
+			i := IfInst{target: r.readLabel(false)}
+			i.moveIf = r.readCondition()
+			inst.val = i
+			break
+		case INST_WRITE:
+			inst.val = r.readValue()
+			break
+		case INST_READ:
+			target := r.readValue()
+			if target.typ == VAL_CONST {
+				r.invalid(target.val)
+			}
+			inst.val = ReadInst{target: target, elseLabel: r.readLabel(true)}
+			break
+		case INST_ASSERT:
+			if version < 2 {
+				r.invalid(inst.typ)
+				break
+			}
+			a := AssertInst{cond: r.readCondition()}
+			if r.err == nil && len(a.cond) == 0 {
+				r.invalid(len(a.cond))
+			}
+			a.message = r.readString()
+			inst.val = a
+			break
+		case INST_SYS:
+			if version < 3 {
+				r.invalid(inst.typ)
+				break
+			}
+			name := r.readString()
+			var count uint16
+			r.read(&count)
+			var args []InstValue
+			for j := 0; j < int(count) && r.err == nil; j++ {
+				args = append(args, r.readValue())
+			}
+			if r.err != nil {
+				break
+			}
+			sys, err := newSysInst(name, args)
+			if err != nil {
+				r.err = err
+				break
+			}
+			inst.val = sys.val
+			break
+		default:
+			r.invalid(inst.typ)
+		}
+		prog.instructions = append(prog.instructions, inst)
+	}
+
+	if r.err == nil && r.r.Len() != 0 {
+		r.invalid(r.r.Len())
+	}
+	if r.err == io.EOF || r.err == io.ErrUnexpectedEOF {
+		return nil, formatError("bytecode", I18N_BYTECODE_ERR_INVALID, r.err)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	for _, target := range targets {
+		if int(target) > len(prog.instructions) {
+			return nil, formatError("bytecode", I18N_BYTECODE_ERR_JUMP, target)
+		}
+	}
+
+	return &prog, nil
+}
+
+// loadProgram compile the source, assemble the listing or load the bytecode of a file
+func loadProgram(filepath string) (*Program, error) {
+	if strings.HasSuffix(filepath, BYTECODE_EXT) {
+		dat, err := os.ReadFile(filepath)
+		if err != nil {
+			return nil, err
+		}
+		return decodeProgram(dat)
+	}
+
+	code, err := read(filepath)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(filepath, LISTING_EXT) {
+		return assembleListing(code)
+	}
+	return compile(code)
+}
+
+// runBuild the `build` command, compile a source into bytecode
+func runBuild(args []string) int {
+	flags := flag.NewFlagSet("build", flag.ExitOnError)
+	output := flags.String("o", "", i18n.Sprintf(I18N_BUILD_FLAG_OUTPUT))
+	optimized := flags.Bool("O", false, i18n.Sprintf(I18N_FLAG_OPTIMIZE))
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		i18n.Println(I18N_ERR_PROG_TOO_MANY_PARMS)
+		return 1
+	}
+	source := flags.Arg(0)
+	if !isProgramFile(source) || strings.HasSuffix(source, BYTECODE_EXT) {
+		i18n.Println(I18N_ERR_PROG_NEED_ASM_EXT)
+		return 1
+	}
+	if *output == "" {
+		*output = strings.TrimSuffix(strings.TrimSuffix(source, ".asm"), LISTING_EXT) + BYTECODE_EXT
+	}
+
+	prog, err := loadProgram(source)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if *optimized {
+		*prog = optimize(*prog)
+	}
+	if err := os.WriteFile(*output, encodeProgram(*prog), 0644); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBytecodeRoundTrip(t *testing.T) {
+	items, err := os.ReadDir(EXAMPLE_FILENAME)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, item := range items {
+		if strings.HasSuffix(item.Name(), ".asm") {
+			prog, err := loadProgram(path.Join(EXAMPLE_FILENAME, item.Name()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := decodeProgram(encodeProgram(*prog))
+			if err != nil {
+				t.Errorf("%v: %v", item.Name(), err)
+				continue
+			}
+			if !reflect.DeepEqual(prog, loaded) {
+				t.Errorf("\nExpected: '%v'\nReceived: '%v'", prog, loaded)
+			}
+		}
+	}
+}
+
+func TestBytecodeValidation(t *testing.T) {
+	prog, err := compile("to end\nwrite 1\nend:\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := encodeProgram(*prog)
+
+	if _, err := decodeProgram(append([]byte("ELF\x00"), data[4:]...)); err == nil {
+		t.Error("\nExpected an error for an invalid magic")
+	}
+
+	version := append([]byte{}, data...)
+	version[len(BYTECODE_MAGIC)] = BYTECODE_VERSION + 1
+	if _, err := decodeProgram(version); err == nil {
+		t.Error("\nExpected an error for an unsupported version")
+	}
+
+	// the single label `end` points to the instruction 2, move it after the end of the program
+	jump := append([]byte{}, data...)
+	i := strings.Index(string(jump), "end") + len("end")
+	jump[i] = 3
+	if _, err := decodeProgram(jump); err == nil {
+		t.Error("\nExpected an error for an invalid jump target")
+	}
+
+	if _, err := decodeProgram(data[:len(data)-1]); err == nil {
+		t.Error("\nExpected an error for a truncated bytecode")
+	}
+}
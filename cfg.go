@@ -0,0 +1,222 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Block a basic block, the instructions in [start, end) are always executed in sequence
+type Block struct {
+	start  int
+	end    int
+	labels []string
+}
+
+const (
+	EDGE_FALL = iota
+	EDGE_JUMP
+	EDGE_ELSE
+)
+
+type Edge struct {
+	from int
+	to   int
+	typ  int
+	cond string
+}
+
+// CFG the control flow graph of a program, the last block is always the empty exit block
+type CFG struct {
+	blocks  []Block
+	edges   []Edge
+	blockOf []int
+}
+
+// isLeader if the instruction starts a new basic block
+func isLeader(prog Program, targets map[int]bool, i int) bool {
+	if i == 0 || targets[i] {
+		return true
+	}
+	prev := prog.instructions[i-1]
+	return prev.typ == INST_TO || (prev.typ == INST_READ && prev.val.(ReadInst).elseLabel != "")
+}
+
+func buildCFG(prog Program) CFG {
+	n := len(prog.instructions)
+	at := labelsAt(prog)
+	targets := make(map[int]bool)
+	for i := range at {
+		targets[i] = true
+	}
+
+	cfg := CFG{blockOf: make([]int, n+1)}
+	for i := 0; i < n; i++ {
+		if isLeader(prog, targets, i) {
+			if len(cfg.blocks) > 0 {
+				cfg.blocks[len(cfg.blocks)-1].end = i
+			}
+			cfg.blocks = append(cfg.blocks, Block{start: i, labels: at[i]})
+		}
+		cfg.blockOf[i] = len(cfg.blocks) - 1
+	}
+	if len(cfg.blocks) > 0 {
+		cfg.blocks[len(cfg.blocks)-1].end = n
+	}
+	cfg.blocks = append(cfg.blocks, Block{start: n, end: n, labels: at[n]})
+	cfg.blockOf[n] = len(cfg.blocks) - 1
+
+	for b, block := range cfg.blocks[:len(cfg.blocks)-1] {
+		last := prog.instructions[block.end-1]
+		next := cfg.blockOf[block.end]
+		switch last.typ {
+		case INST_TO:
+			i := last.val.(IfInst)
+			target := cfg.blockOf[prog.labels[i.target]]
+			if i.moveIf == nil {
+				cfg.edges = append(cfg.edges, Edge{from: b, to: target, typ: EDGE_JUMP})
+			} else {
+				cond := conditionText(i.moveIf)
+				cfg.edges = append(cfg.edges, Edge{from: b, to: target, typ: EDGE_JUMP, cond: cond})
+				cfg.edges = append(cfg.edges, Edge{from: b, to: next, typ: EDGE_FALL, cond: "!(" + cond + ")"})
+			}
+			break
+		case INST_READ:
+			if label := last.val.(ReadInst).elseLabel; label != "" {
+				cfg.edges = append(cfg.edges, Edge{from: b, to: next, typ: EDGE_FALL, cond: "read"})
+				cfg.edges = append(cfg.edges, Edge{from: b, to: cfg.blockOf[prog.labels[label]], typ: EDGE_ELSE, cond: "eof"})
+				break
+			}
+			cfg.edges = append(cfg.edges, Edge{from: b, to: next, typ: EDGE_FALL})
+			break
+		default:
+			cfg.edges = append(cfg.edges, Edge{from: b, to: next, typ: EDGE_FALL})
+		}
+	}
+
+	return cfg
+}
+
+// findEdge the edge leaving the block with the type
+func (cfg CFG) findEdge(from int, typ int) int {
+	for i, e := range cfg.edges {
+		if e.from == from && e.typ == typ {
+			return i
+		}
+	}
+	return -1
+}
+
+// profileCFG how many times each edge was taken while executing the program, at most `maxSteps` instructions
+func profileCFG(prog Program, cfg CFG, input []int64, maxSteps int) ([]int64, error) {
+	counts := make([]int64, len(cfg.edges))
+	vm := newVM(prog, input)
+	vm.maxSteps = maxSteps
+	for !vm.done() {
+		pc := vm.pc
+		if err := vm.step(); err != nil {
+			return counts, err
+		}
+
+		b := cfg.blockOf[pc]
+		if pc != cfg.blocks[b].end-1 {
+			continue
+		}
+		typ := EDGE_FALL
+		if vm.jumped {
+			typ = EDGE_JUMP
+			if prog.instructions[pc].typ == INST_READ {
+				typ = EDGE_ELSE
+			}
+		}
+		if e := cfg.findEdge(b, typ); e >= 0 {
+			counts[e]++
+		}
+	}
+	return counts, nil
+}
+
+// dotString quote a text to be used inside a DOT label
+func dotString(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(text)
+}
+
+// exportDOT render the control flow graph in the Graphviz DOT language, `counts` is optional
+func exportDOT(name string, prog Program, cfg CFG, counts []int64) string {
+	var sb strings.Builder
+	exit := len(cfg.blocks) - 1
+
+	sb.WriteString(fmt.Sprintf("digraph \"%s\" {\n", dotString(name)))
+	sb.WriteString("\tnode [shape=box fontname=\"monospace\"];\n")
+	for b, block := range cfg.blocks {
+		var label strings.Builder
+		for _, name := range block.labels {
+			label.WriteString(name + ":\\l")
+		}
+		if b == exit {
+			label.WriteString("exit\\l")
+			sb.WriteString(fmt.Sprintf("\tb%d [label=\"%s\" shape=oval];\n", b, label.String()))
+			continue
+		}
+		for i := block.start; i < block.end; i++ {
+			label.WriteString(fmt.Sprintf("%d: %s\\l", i, dotString(instructionText(prog.instructions[i]))))
+		}
+		sb.WriteString(fmt.Sprintf("\tb%d [label=\"%s\"];\n", b, label.String()))
+	}
+	for i, e := range cfg.edges {
+		label := e.cond
+		if counts != nil {
+			label = strings.TrimSpace(fmt.Sprintf("%s (%d)", label, counts[i]))
+		}
+		style := ""
+		if e.typ != EDGE_FALL {
+			style = " style=bold"
+		}
+		sb.WriteString(fmt.Sprintf("\tb%d -> b%d [label=\"%s\"%s];\n", e.from, e.to, dotString(label), style))
+	}
+	sb.WriteString("}\n")
+
+	return sb.String()
+}
+
+// runCFG the `cfg` command, print the control flow graph in DOT
+func runCFG(args []string) int {
+	flags := flag.NewFlagSet("cfg", flag.ExitOnError)
+	profile := flags.Bool("profile", false, i18n.Sprintf(I18N_CFG_FLAG_PROFILE))
+	maxSteps := flags.Int("max-steps", GOLDEN_MAX_STEPS, i18n.Sprintf(I18N_FLAG_MAX_STEPS))
+	flags.Parse(args)
+
+	if flags.NArg() == 0 || flags.NArg() > 2 {
+		i18n.Println(I18N_ERR_PROG_TOO_MANY_PARMS)
+		return 1
+	}
+	if !isProgramFile(flags.Arg(0)) {
+		i18n.Println(I18N_ERR_PROG_NEED_ASM_EXT)
+		return 1
+	}
+
+	prog, err := loadProgram(flags.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	cfg := buildCFG(*prog)
+
+	var counts []int64
+	if *profile {
+		input, err := readInput(flags.Arg(1))
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		counts, err = profileCFG(*prog, cfg, input, *maxSteps)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+	}
+
+	fmt.Print(exportDOT(path.Base(flags.Arg(0)), *prog, cfg, counts))
+	return 0
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"path"
+	"testing"
+)
+
+func TestCFGProfile(t *testing.T) {
+	prog, err := loadProgram(path.Join(EXAMPLE_FILENAME, "fibonacci.asm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := buildCFG(*prog)
+
+	// to main | fibonacci | loop body | loop end | main | end | exit
+	if len(cfg.blocks) != 7 {
+		t.Fatalf("\nExpected: 7 blocks\nReceived: %d", len(cfg.blocks))
+	}
+
+	counts, err := profileCFG(*prog, cfg, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back := -1
+	for i, e := range cfg.edges {
+		if e.from == 3 && e.to == 1 {
+			back = i
+		}
+	}
+	if back < 0 {
+		t.Fatal("\nExpected a back edge from the loop end to the loop start")
+	}
+	if counts[back] != 55 {
+		t.Errorf("\nExpected: 55\nReceived: %d", counts[back])
+	}
+}
+
+func TestCFGProfileLimit(t *testing.T) {
+	prog, err := compile("aa:\nto aa\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := buildCFG(*prog)
+	counts, err := profileCFG(*prog, cfg, nil, 100)
+	expected := "[Execution error: line 2] <[limit]> too many instructions executed, the limit is: 100."
+	if err == nil || err.Error() != expected {
+		t.Fatalf("\nExpected: '%v'\nReceived: '%v'", expected, err)
+	}
+	if counts[0] != 100 {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", 100, counts[0])
+	}
+}
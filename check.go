@@ -0,0 +1,219 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+)
+
+// SlotSet a set of memory slots
+type SlotSet [MEMORY_SIZE / 64]uint64
+
+func (s *SlotSet) add(slot int64) {
+	s[slot/64] |= 1 << uint(slot%64)
+}
+
+func (s SlotSet) has(slot int64) bool {
+	return s[slot/64]&(1<<uint(slot%64)) != 0
+}
+
+func (s SlotSet) intersect(o SlotSet) SlotSet {
+	for i := range s {
+		s[i] &= o[i]
+	}
+	return s
+}
+
+// fullSlotSet the set with every slot
+func fullSlotSet() SlotSet {
+	var s SlotSet
+	for i := range s {
+		s[i] = ^uint64(0)
+	}
+	return s
+}
+
+// valueSlot the slot read to get a value, for a reference only the slot with the address is known
+func valueSlot(v InstValue) (int64, bool) {
+	if v.typ == VAL_CONST {
+		return 0, false
+	}
+	return v.val, true
+}
+
+// instUses the values read by the instruction
+func instUses(inst Instruction) []InstValue {
+	switch inst.typ {
+	case INST_OP:
+		op := inst.val.(Operation)
+		uses := []InstValue{op.v1, op.v2}
+		if op.v.typ == VAL_REF {
+			uses = append(uses, op.v)
+		}
+		return uses
+	case INST_TO:
+		var uses []InstValue
+		for i, p := range inst.val.(IfInst).moveIf {
+			if ifInstOrder(i) == IFO_VAL {
+				uses = append(uses, p.(InstValue))
+			}
+		}
+		return uses
+	case INST_WRITE:
+		return []InstValue{inst.val.(InstValue)}
+	case INST_ASSERT:
+		var uses []InstValue
+		for i, p := range inst.val.(AssertInst).cond {
+			if ifInstOrder(i) == IFO_VAL {
+				uses = append(uses, p.(InstValue))
+			}
+		}
+		return uses
+	case INST_READ:
+		if target := inst.val.(ReadInst).target; target.typ == VAL_REF {
+			return []InstValue{target}
+		}
+		return nil
+	case INST_SYS:
+		// a variable argument can be a result of the syscall, only the references are surely read
+		var uses []InstValue
+		for _, arg := range inst.val.(SysInst).args {
+			if arg.typ == VAL_REF {
+				uses = append(uses, arg)
+			}
+		}
+		return uses
+	}
+	panic("IMPOSSIBLE")
+}
+
+// instDefs the slots written by the instruction, writes through a reference can't be known
+func instDefs(inst Instruction) []int64 {
+	var targets []InstValue
+	switch inst.typ {
+	case INST_OP:
+		targets = []InstValue{inst.val.(Operation).v}
+		break
+	case INST_READ:
+		targets = []InstValue{inst.val.(ReadInst).target}
+		break
+	case INST_SYS:
+		targets = inst.val.(SysInst).args
+		break
+	}
+	var defs []int64
+	for _, target := range targets {
+		if target.typ == VAL_VAR {
+			defs = append(defs, target.val)
+		}
+	}
+	return defs
+}
+
+type Warning struct {
+	line int
+	slot int64
+}
+
+// checkUninitialized find the variables that may be read before anything writes them,
+// a slot is only initialized when it is written in every path that reaches the read
+func checkUninitialized(prog Program) []Warning {
+	cfg := buildCFG(prog)
+	exit := len(cfg.blocks) - 1
+
+	in := make([]SlotSet, len(cfg.blocks))
+	reached := make([]bool, len(cfg.blocks))
+	for b := range in {
+		in[b] = fullSlotSet()
+	}
+	if exit > 0 {
+		in[0] = SlotSet{}
+		reached[0] = true
+	}
+
+	// out is the state after the block, the else edge of a `read` skips the write of the last instruction
+	transfer := func(b int, typ int) SlotSet {
+		state := in[b]
+		block := cfg.blocks[b]
+		for i := block.start; i < block.end; i++ {
+			if i == block.end-1 && typ == EDGE_ELSE {
+				break
+			}
+			for _, slot := range instDefs(prog.instructions[i]) {
+				state.add(slot)
+			}
+		}
+		return state
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for b := 1; b < exit; b++ {
+			state := fullSlotSet()
+			reach := false
+			for _, e := range cfg.edges {
+				if e.to == b && reached[e.from] {
+					state = state.intersect(transfer(e.from, e.typ))
+					reach = true
+				}
+			}
+			if reach && (!reached[b] || state != in[b]) {
+				in[b] = state
+				reached[b] = true
+				changed = true
+			}
+		}
+	}
+
+	var warnings []Warning
+	for b := 0; b < exit; b++ {
+		if !reached[b] {
+			continue
+		}
+		state := in[b]
+		block := cfg.blocks[b]
+		for i := block.start; i < block.end; i++ {
+			inst := prog.instructions[i]
+			reported := make(map[int64]bool)
+			for _, v := range instUses(inst) {
+				slot, ok := valueSlot(v)
+				if ok && !state.has(slot) && !reported[slot] {
+					reported[slot] = true
+					warnings = append(warnings, Warning{line: inst.line, slot: slot})
+				}
+			}
+			for _, slot := range instDefs(inst) {
+				state.add(slot)
+			}
+		}
+	}
+
+	sort.SliceStable(warnings, func(i, j int) bool {
+		return warnings[i].line < warnings[j].line
+	})
+	return warnings
+}
+
+// runCheck the `check` command, print the warnings of the static analysis
+func runCheck(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	flags.Parse(args)
+
+	status := 0
+	for _, filepath := range flags.Args() {
+		if !isProgramFile(filepath) {
+			i18n.Println(I18N_ERR_PROG_NEED_ASM_EXT)
+			return 1
+		}
+		prog, err := loadProgram(filepath)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		for _, w := range checkUninitialized(*prog) {
+			i18n.Printf(I18N_CHECK_WARN_TEMPLATE, filepath, w.line, i18n.Sprintf(I18N_CHECK_WARN_UNINITIALIZED, w.slot))
+			status = 1
+		}
+	}
+	return status
+}
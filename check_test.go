@@ -0,0 +1,29 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckUninitialized(t *testing.T) {
+	code := `read $0 end
+$1 = $0 + $5
+to skip if $1 > 0
+$2 = 1
+skip:
+  write $2
+  write &7
+  $3 = $1
+end:
+  write $0
+  write $3`
+
+	prog, err := compile(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Warning{{line: 2, slot: 5}, {line: 6, slot: 2}, {line: 7, slot: 7}, {line: 10, slot: 0}, {line: 11, slot: 3}}
+	if warnings := checkUninitialized(*prog); !reflect.DeepEqual(warnings, expected) {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", expected, warnings)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CostTable the cycles of each kind of instruction, plus the cycles to access each operand
+type CostTable struct {
+	Move   int64 `json:"move" yaml:"move"`
+	Add    int64 `json:"add" yaml:"add"`
+	Sub    int64 `json:"sub" yaml:"sub"`
+	Mul    int64 `json:"mul" yaml:"mul"`
+	Div    int64 `json:"div" yaml:"div"`
+	Jump   int64 `json:"jump" yaml:"jump"`
+	Write  int64 `json:"write" yaml:"write"`
+	Read   int64 `json:"read" yaml:"read"`
+	Assert int64 `json:"assert" yaml:"assert"`
+	Sys    int64 `json:"sys" yaml:"sys"`
+	// Compare each comparison of a condition
+	Compare int64 `json:"compare" yaml:"compare"`
+	// Taken the extra cycles of a `to` that jumps, or of a `read` that goes to its else label
+	Taken int64 `json:"taken" yaml:"taken"`
+	Const int64 `json:"const" yaml:"const"`
+	Var   int64 `json:"var" yaml:"var"`
+	Ref   int64 `json:"ref" yaml:"ref"`
+}
+
+// DEFAULT_COSTS the multiplication and the division are slower, `&` reads memory twice and a syscall leaves the VM
+var DEFAULT_COSTS = CostTable{
+	Move:    1,
+	Add:     1,
+	Sub:     1,
+	Mul:     3,
+	Div:     8,
+	Jump:    1,
+	Write:   2,
+	Read:    2,
+	Assert:  1,
+	Sys:     10,
+	Compare: 1,
+	Taken:   2,
+	Const:   0,
+	Var:     1,
+	Ref:     3,
+}
+
+// decodeConfig read a YAML file, for the `.yaml` and `.yml` extensions, or a JSON file into `v`,
+// the fields that aren't in the file keep their values
+func decodeConfig(path string, v interface{}) error {
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".yaml" || ext == ".yml" {
+		dec := yaml.NewDecoder(bytes.NewReader(dat))
+		dec.KnownFields(true)
+		err = dec.Decode(v)
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(dat))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(v)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// loadCosts the default costs changed by the file, empty is only the default
+func loadCosts(path string) (*CostTable, error) {
+	costs := DEFAULT_COSTS
+	if path == "" {
+		return &costs, nil
+	}
+	if err := decodeConfig(path, &costs); err != nil {
+		return nil, err
+	}
+	return &costs, nil
+}
+
+func (c CostTable) operand(v InstValue) int64 {
+	switch v.typ {
+	case VAL_VAR:
+		return c.Var
+	case VAL_REF:
+		return c.Ref
+	}
+	return c.Const
+}
+
+// condition the comparisons and the operands of a condition compiled by `compileCondition`
+func (c CostTable) condition(cond []interface{}) int64 {
+	var cost int64
+	for i, p := range cond {
+		switch ifInstOrder(i) {
+		case IFO_VAL:
+			cost += c.operand(p.(InstValue))
+			break
+		case IFO_CMP:
+			cost += c.Compare
+			break
+		}
+	}
+	return cost
+}
+
+// cycles the cost of executing the instruction, `taken` when it moved to a label
+func (c CostTable) cycles(inst Instruction, taken bool) int64 {
+	var cost int64
+	switch inst.typ {
+	case INST_OP:
+		op := inst.val.(Operation)
+		cost = c.operand(op.v) + c.operand(op.v1)
+		switch op.op {
+		case OP_UNI:
+			cost += c.Move
+			break
+		case OP_ADD:
+			cost += c.Add + c.operand(op.v2)
+			break
+		case OP_SUB:
+			cost += c.Sub + c.operand(op.v2)
+			break
+		case OP_MUL:
+			cost += c.Mul + c.operand(op.v2)
+			break
+		case OP_DIV:
+			cost += c.Div + c.operand(op.v2)
+			break
+		}
+		break
+	case INST_TO:
+		cost = c.Jump + c.condition(inst.val.(IfInst).moveIf)
+		break
+	case INST_WRITE:
+		cost = c.Write + c.operand(inst.val.(InstValue))
+		break
+	case INST_READ:
+		cost = c.Read + c.operand(inst.val.(ReadInst).target)
+		break
+	case INST_ASSERT:
+		cost = c.Assert + c.condition(inst.val.(AssertInst).cond)
+		break
+	case INST_SYS:
+		cost = c.Sys
+		for _, arg := range inst.val.(SysInst).args {
+			cost += c.operand(arg)
+		}
+		break
+	}
+	if taken {
+		cost += c.Taken
+	}
+	return cost
+}
+
+// Stats the measures of an execution, the size is the number of instructions
+type Stats struct {
+	Steps  int   `json:"steps"`
+	Cycles int64 `json:"cycles"`
+	Size   int   `json:"size"`
+}
+
+// executeStats execute at most `maxSteps` instructions with the costs, 0 is no limit
+func executeStats(prog Program, input []int64, maxSteps int, costs CostTable) ([]WriteResult, Stats, error) {
+	vm := newVM(prog, input)
+	vm.maxSteps = maxSteps
+	vm.costs = &costs
+	err := vm.run()
+	return vm.results, vm.stats(), err
+}
+
+// stats the measures of the execution until now
+func (vm *VM) stats() Stats {
+	return Stats{vm.steps, vm.cycles, len(vm.prog.instructions)}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCycles(t *testing.T) {
+	cases := []struct {
+		code   string
+		cycles int64
+	}{
+		{"$0 = 1", DEFAULT_COSTS.Move + DEFAULT_COSTS.Var},
+		{"$0 = $1 + 2", DEFAULT_COSTS.Add + 2*DEFAULT_COSTS.Var},
+		{"$0 = $1 * 2", DEFAULT_COSTS.Mul + 2*DEFAULT_COSTS.Var},
+		{"$0 = 5\n&0 = $0 / 2", DEFAULT_COSTS.Move + DEFAULT_COSTS.Var + DEFAULT_COSTS.Div + DEFAULT_COSTS.Ref + DEFAULT_COSTS.Var},
+		{"write &0", DEFAULT_COSTS.Write + DEFAULT_COSTS.Ref},
+		// not taken and then taken
+		{"to end if $0 > 0\nto end if $0 == 0\nend:", 2*(DEFAULT_COSTS.Jump+DEFAULT_COSTS.Compare+DEFAULT_COSTS.Var) + DEFAULT_COSTS.Taken},
+		{"read $0 end\nend:", DEFAULT_COSTS.Read + DEFAULT_COSTS.Var + DEFAULT_COSTS.Taken},
+		{"assert 1 < 2 && $0 == 0", DEFAULT_COSTS.Assert + 2*DEFAULT_COSTS.Compare + DEFAULT_COSTS.Var},
+	}
+	for _, c := range cases {
+		prog, err := compile(c.code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, stats, err := executeStats(*prog, nil, 0, DEFAULT_COSTS)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Cycles != c.cycles {
+			t.Errorf("%q\nExpected: '%v'\nReceived: '%v'", c.code, c.cycles, stats.Cycles)
+		}
+		if stats.Size != len(prog.instructions) {
+			t.Errorf("%q\nExpected: '%v'\nReceived: '%v'", c.code, len(prog.instructions), stats.Size)
+		}
+	}
+}
+
+func TestLoadCosts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "costs.yaml")
+	if err := os.WriteFile(path, []byte("mul: 10\nref: 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	costs, err := loadCosts(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := DEFAULT_COSTS
+	expected.Mul = 10
+	expected.Ref = 0
+	if *costs != expected {
+		t.Errorf("\nExpected: %+v\nReceived: %+v", expected, *costs)
+	}
+
+	if err := os.WriteFile(path, []byte("mult: 10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadCosts(path); err == nil {
+		t.Errorf("\nExpected: an error\nReceived: '%v'", err)
+	}
+}
+
+func TestLeaderboard(t *testing.T) {
+	reports := []SubmissionReport{
+		{File: "slow.asm", Score: 5, Cycles: 90, Size: 4},
+		{File: "wrong.asm", Score: 2, Cycles: 10, Size: 2},
+		{File: "big.asm", Score: 5, Cycles: 50, Size: 9},
+		{File: "fast.asm", Score: 5, Cycles: 50, Size: 6},
+	}
+	expected := []string{"fast.asm", "big.asm", "slow.asm", "wrong.asm"}
+	for i, r := range leaderboard(reports) {
+		if r.File != expected[i] {
+			t.Errorf("%d\nExpected: '%v'\nReceived: '%v'", i+1, expected[i], r.File)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Coverage the instructions and the branches executed by all the runs of a program,
+// a branch is a `to` with a condition and it is covered once it jumped and once it didn't
+type Coverage struct {
+	prog Program
+	runs int
+	// hits how many times each instruction was executed
+	hits []int64
+	// jumps and falls how many times each `to` jumped or moved to the next instruction
+	jumps []int64
+	falls []int64
+}
+
+func newCoverage(prog Program) *Coverage {
+	n := len(prog.instructions)
+	return &Coverage{prog: prog, hits: make([]int64, n), jumps: make([]int64, n), falls: make([]int64, n)}
+}
+
+// isBranch if the instruction can either jump or continue
+func isBranch(inst Instruction) bool {
+	return inst.typ == INST_TO && len(inst.val.(IfInst).moveIf) > 0
+}
+
+// run execute the program once more, the instruction that fails also counts as executed
+func (c *Coverage) run(input []int64, maxSteps int) error {
+	c.runs++
+	vm := newVM(c.prog, input)
+	vm.maxSteps = maxSteps
+	for !vm.done() {
+		pc, steps := vm.pc, vm.steps
+		err := vm.step()
+		if vm.steps > steps {
+			c.hits[pc]++
+		}
+		if err != nil {
+			return err
+		}
+		if c.prog.instructions[pc].typ == INST_TO {
+			if vm.jumped {
+				c.jumps[pc]++
+			} else {
+				c.falls[pc]++
+			}
+		}
+	}
+	return nil
+}
+
+// percent the covered part of the total, an empty total is fully covered
+func percent(covered int, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return 100 * float64(covered) / float64(total)
+}
+
+// summary the instructions executed and the branch outcomes seen, each branch has two outcomes
+func (c *Coverage) summary() (hit int, total int, branchesHit int, branches int) {
+	for i, inst := range c.prog.instructions {
+		total++
+		if c.hits[i] > 0 {
+			hit++
+		}
+		if isBranch(inst) {
+			branches += 2
+			if c.jumps[i] > 0 {
+				branchesHit++
+			}
+			if c.falls[i] > 0 {
+				branchesHit++
+			}
+		}
+	}
+	return hit, total, branchesHit, branches
+}
+
+// annotate the source with how many times each line was executed, `#####` when never,
+// without the source the lines are the instructions
+func (c *Coverage) annotate(source []string) string {
+	var sb strings.Builder
+	line := func(count string, text string, inst int) {
+		sb.WriteString(fmt.Sprintf("%7s | %s", count, text))
+		if inst >= 0 && isBranch(c.prog.instructions[inst]) {
+			sb.WriteString(i18n.Sprintf(I18N_COVER_BRANCH, c.jumps[inst], c.falls[inst]))
+		}
+		sb.WriteString("\n")
+	}
+	count := func(inst int) string {
+		if c.hits[inst] == 0 {
+			return "#####"
+		}
+		return fmt.Sprint(c.hits[inst])
+	}
+
+	if source == nil {
+		for i, inst := range c.prog.instructions {
+			line(count(i), instructionText(inst), i)
+		}
+		return sb.String()
+	}
+	atLine := make(map[int]int)
+	for i, inst := range c.prog.instructions {
+		atLine[inst.line] = i
+	}
+	for i, text := range source {
+		if inst, ok := atLine[i+1]; ok {
+			line(count(inst), text, inst)
+		} else {
+			line("-", text, -1)
+		}
+	}
+	return sb.String()
+}
+
+// writeLCOV the coverage in the LCOV tracefile format, the block of a branch is the index of its instruction,
+// the branch 0 is the jump and the branch 1 is the next instruction
+func (c *Coverage) writeLCOV(w io.Writer, file string) {
+	hit, total, branchesHit, branches := c.summary()
+	fmt.Fprintln(w, "TN:")
+	fmt.Fprintf(w, "SF:%s\n", file)
+	for i, inst := range c.prog.instructions {
+		if !isBranch(inst) {
+			continue
+		}
+		if c.hits[i] == 0 {
+			fmt.Fprintf(w, "BRDA:%d,%d,0,-\nBRDA:%d,%d,1,-\n", inst.line, i, inst.line, i)
+			continue
+		}
+		fmt.Fprintf(w, "BRDA:%d,%d,0,%d\nBRDA:%d,%d,1,%d\n", inst.line, i, c.jumps[i], inst.line, i, c.falls[i])
+	}
+	fmt.Fprintf(w, "BRF:%d\nBRH:%d\n", branches, branchesHit)
+	for i, inst := range c.prog.instructions {
+		fmt.Fprintf(w, "DA:%d,%d\n", inst.line, c.hits[i])
+	}
+	fmt.Fprintf(w, "LF:%d\nLH:%d\n", total, hit)
+	fmt.Fprintln(w, "end_of_record")
+}
+
+// runCover the `cover` command, execute the program once for each input file and show what was executed
+func runCover(args []string) int {
+	flags := flag.NewFlagSet("cover", flag.ExitOnError)
+	maxSteps := flags.Int("max-steps", GOLDEN_MAX_STEPS, i18n.Sprintf(I18N_FLAG_MAX_STEPS))
+	lcov := flags.String("lcov", "", i18n.Sprintf(I18N_COVER_FLAG_LCOV))
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		printUsage()
+		return 1
+	}
+	file := flags.Arg(0)
+	if !isProgramFile(file) {
+		i18n.Println(I18N_ERR_PROG_NEED_ASM_EXT)
+		return 1
+	}
+	prog, err := loadProgram(file)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	var source []string
+	if strings.HasSuffix(file, ".asm") {
+		code, err := read(file)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		source = strings.Split(strings.TrimSuffix(code, "\n"), "\n")
+	}
+
+	// without input files the program runs once without input
+	inputs := flags.Args()[1:]
+	if len(inputs) == 0 {
+		inputs = []string{""}
+	}
+	cover := newCoverage(*prog)
+	for _, name := range inputs {
+		input, err := readInput(name)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		if err := cover.run(input, *maxSteps); err != nil {
+			i18n.Printf(I18N_COVER_RUN_ERROR, name, err)
+		}
+	}
+
+	fmt.Print(cover.annotate(source))
+	hit, total, branchesHit, branches := cover.summary()
+	i18n.Printf(I18N_COVER_SUMMARY, cover.runs, percent(hit, total), hit, total, percent(branchesHit, branches), branchesHit, branches)
+
+	if *lcov != "" {
+		out, err := os.Create(*lcov)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		defer out.Close()
+		cover.writeLCOV(out, file)
+	}
+	return 0
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	code := "read $0\nto neg if $0 < 0\nwrite $0\nto end\nneg:\nwrite 0\nend:\n"
+	prog, err := compile(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cover := newCoverage(*prog)
+	if err := cover.run([]int64{5}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if hit, total, branchesHit, branches := cover.summary(); hit != 4 || total != 5 || branchesHit != 1 || branches != 2 {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", []int{4, 5, 1, 2}, []int{hit, total, branchesHit, branches})
+	}
+	if err := cover.run(nil, 0); err == nil {
+		t.Errorf("\nExpected: an error\nReceived: '%v'", err)
+	}
+	if err := cover.run([]int64{-5}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if hit, total, branchesHit, branches := cover.summary(); hit != 5 || total != 5 || branchesHit != 2 || branches != 2 {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", []int{5, 5, 2, 2}, []int{hit, total, branchesHit, branches})
+	}
+
+	expected := `      3 | read $0
+      2 | to neg if $0 < 0  [saltou 1, continuou 1]
+      1 | write $0
+      1 | to end
+      - | neg:
+      1 | write 0
+      - | end:
+`
+	if listing := cover.annotate(strings.Split(strings.TrimSuffix(code, "\n"), "\n")); listing != expected {
+		t.Errorf("\nExpected:\n%s\nReceived:\n%s", expected, listing)
+	}
+
+	var lcov strings.Builder
+	cover.writeLCOV(&lcov, "sign.asm")
+	for _, line := range []string{"SF:sign.asm", "BRDA:2,1,0,1", "BRDA:2,1,1,1", "BRF:2", "BRH:2", "DA:1,3", "DA:6,1", "LF:5", "LH:5", "end_of_record"} {
+		if !strings.Contains(lcov.String(), line+"\n") {
+			t.Errorf("\nExpected: '%v'\nReceived: '%v'", line, lcov.String())
+		}
+	}
+
+	unused := newCoverage(*prog)
+	unused.writeLCOV(&lcov, "sign.asm")
+	if !strings.Contains(lcov.String(), "BRDA:2,1,0,-") {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", "BRDA:2,1,0,-", lcov.String())
+	}
+}
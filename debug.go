@@ -0,0 +1,396 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	DEBUG_PROMPT = "(fasm) "
+	// DEBUG_HISTORY_SIZE the most steps that can be undone, the older ones are forgotten
+	DEBUG_HISTORY_SIZE = 1000000
+)
+
+// HistoryStep the state before one step, enough to undo it
+type HistoryStep struct {
+	pc      int
+	rc      int
+	results int
+	cycles  int64
+	// writes the memory slots that the step may write, with their values before it
+	writes []HistoryWrite
+}
+
+type HistoryWrite struct {
+	slot int
+	old  int64
+}
+
+// History the last steps executed by the VM, a ring buffer once it has `limit` steps
+type History struct {
+	steps []HistoryStep
+	// first the index of the oldest step in `steps`, size how many steps are kept
+	first int
+	size  int
+	// limit the most steps kept, 0 keeps all of them
+	limit int
+}
+
+func newHistory(limit int) *History {
+	return &History{limit: limit}
+}
+
+// at the step `i` counting from the oldest kept
+func (h *History) at(i int) HistoryStep {
+	return h.steps[(h.first+i)%len(h.steps)]
+}
+
+// push keep the step, the oldest is forgotten when there are already `limit` steps
+func (h *History) push(s HistoryStep) {
+	switch {
+	case h.size < len(h.steps):
+		h.steps[(h.first+h.size)%len(h.steps)] = s
+		h.size++
+		break
+	case h.limit == 0 || len(h.steps) < h.limit:
+		// the buffer only wraps once it is full, until then `first` is 0
+		h.steps = append(h.steps, s)
+		h.size++
+		break
+	default:
+		h.steps[h.first] = s
+		h.first = (h.first + 1) % len(h.steps)
+		break
+	}
+}
+
+// pop forget the newest step, false when there is none
+func (h *History) pop() (HistoryStep, bool) {
+	if h.size == 0 {
+		return HistoryStep{}, false
+	}
+	h.size--
+	return h.at(h.size), true
+}
+
+// writtenSlots the memory slots that the instruction pointed by `pc` may write, a syscall may write any of its arguments
+func (vm *VM) writtenSlots() []int {
+	inst := vm.prog.instructions[vm.pc]
+	var targets []InstValue
+	switch inst.typ {
+	case INST_OP:
+		targets = []InstValue{inst.val.(Operation).v}
+		break
+	case INST_READ:
+		if vm.rc < len(vm.input) {
+			targets = []InstValue{inst.val.(ReadInst).target}
+		}
+		break
+	case INST_SYS:
+		targets = inst.val.(SysInst).args
+		break
+	}
+	var slots []int
+	for _, target := range targets {
+		if target.typ == VAL_CONST {
+			continue
+		}
+		if addr, err := addressFromMem(vm.mem, target); err == nil {
+			slots = append(slots, int(addr))
+		}
+	}
+	return slots
+}
+
+// record remember the state before the step that is about to be executed
+func (h *History) record(vm *VM) {
+	s := HistoryStep{pc: vm.pc, rc: vm.rc, results: len(vm.results), cycles: vm.cycles}
+	for _, slot := range vm.writtenSlots() {
+		s.writes = append(s.writes, HistoryWrite{slot: slot, old: vm.mem[slot]})
+	}
+	h.push(s)
+}
+
+// back undo the last step, false when there is no history or no step to undo
+func (vm *VM) back() bool {
+	if vm.history == nil {
+		return false
+	}
+	s, ok := vm.history.pop()
+	if !ok {
+		return false
+	}
+	// in the reverse order, a slot written twice gets the oldest value
+	for i := len(s.writes) - 1; i >= 0; i-- {
+		vm.mem[s.writes[i].slot] = s.writes[i].old
+	}
+	vm.pc = s.pc
+	vm.rc = s.rc
+	vm.results = vm.results[:s.results]
+	vm.cycles = s.cycles
+	vm.steps--
+	vm.jumped = false
+	return true
+}
+
+// lastChange the step that last changed the value of the slot and the value before it, 0 when it never changed
+// in the steps kept, writing the same value isn't a change
+func (vm *VM) lastChange(slot int) (int, HistoryStep, int64) {
+	if vm.history == nil {
+		return 0, HistoryStep{}, 0
+	}
+	after := vm.mem[slot]
+	for i := vm.history.size - 1; i >= 0; i-- {
+		s := vm.history.at(i)
+		for j := len(s.writes) - 1; j >= 0; j-- {
+			if s.writes[j].slot != slot {
+				continue
+			}
+			if s.writes[j].old != after {
+				return vm.steps - vm.history.size + i + 1, s, s.writes[j].old
+			}
+			after = s.writes[j].old
+		}
+	}
+	return 0, HistoryStep{}, 0
+}
+
+// Debugger a VM controlled by commands, the last steps are kept in the history so they can be undone
+type Debugger struct {
+	vm          *VM
+	err         error
+	breakpoints map[int]bool
+	out         io.Writer
+}
+
+func newDebugger(prog Program, input []int64, maxSteps int, out io.Writer) *Debugger {
+	vm := newVM(prog, input)
+	vm.maxSteps = maxSteps
+	vm.history = newHistory(DEBUG_HISTORY_SIZE)
+	return &Debugger{vm: vm, breakpoints: map[int]bool{}, out: out}
+}
+
+func (d *Debugger) finished() bool {
+	return d.err != nil || d.vm.done()
+}
+
+// atBreakpoint if the next instruction is in a line with a breakpoint
+func (d *Debugger) atBreakpoint() bool {
+	return !d.vm.done() && d.breakpoints[d.vm.prog.instructions[d.vm.pc].line]
+}
+
+func (d *Debugger) forward() bool {
+	if d.finished() {
+		return false
+	}
+	d.err = d.vm.step()
+	return true
+}
+
+// backward undo the last step, the error of the step that failed is forgotten
+func (d *Debugger) backward() bool {
+	if !d.vm.back() {
+		return false
+	}
+	d.err = nil
+	return true
+}
+
+// cont move with `move` at least once and until the next instruction has a breakpoint
+func (d *Debugger) cont(move func() bool) {
+	for move() && !d.atBreakpoint() {
+	}
+}
+
+// where the step and the next instruction, or the error that stopped the program
+func (d *Debugger) where() {
+	switch {
+	case d.err != nil:
+		i18n.Fprintf(d.out, I18N_DEBUG_STOPPED, d.vm.steps, d.err)
+		break
+	case d.vm.done():
+		i18n.Fprintf(d.out, I18N_DEBUG_ENDED, d.vm.steps)
+		break
+	default:
+		inst := d.vm.prog.instructions[d.vm.pc]
+		i18n.Fprintf(d.out, I18N_DEBUG_AT, d.vm.steps, inst.line, instructionText(inst))
+		break
+	}
+}
+
+// number a positive number typed by the user, 0 when it is invalid
+func (d *Debugger) number(args []string) int {
+	if len(args) == 0 {
+		i18n.Fprintf(d.out, I18N_DEBUG_ERR_NUMBER, "")
+		return 0
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 {
+		i18n.Fprintf(d.out, I18N_DEBUG_ERR_NUMBER, args[0])
+		return 0
+	}
+	return n
+}
+
+// count how many times to repeat the command, 1 when it isn't typed
+func (d *Debugger) count(args []string) int {
+	if len(args) == 0 {
+		return 1
+	}
+	return d.number(args)
+}
+
+func (d *Debugger) printBreakpoints() {
+	var lines []int
+	for l := range d.breakpoints {
+		lines = append(lines, l)
+	}
+	sort.Ints(lines)
+	text := make([]string, len(lines))
+	for i, l := range lines {
+		text[i] = strconv.Itoa(l)
+	}
+	i18n.Fprintf(d.out, I18N_DEBUG_BREAKPOINTS, strings.Join(text, " "))
+}
+
+func (d *Debugger) slot(args []string) int {
+	if len(args) == 1 && strings.HasPrefix(args[0], "$") {
+		if n, err := strconv.Atoi(args[0][1:]); err == nil && n >= 0 && n < MEMORY_SIZE {
+			return n
+		}
+	}
+	if len(args) == 0 {
+		i18n.Fprintf(d.out, I18N_DEBUG_ERR_SLOT, "")
+	} else {
+		i18n.Fprintf(d.out, I18N_DEBUG_ERR_SLOT, args[0])
+	}
+	return -1
+}
+
+// command execute one line typed by the user, false to quit
+func (d *Debugger) command(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return true
+	}
+	args := fields[1:]
+	switch fields[0] {
+	case "q", "quit":
+		return false
+	case "s", "step":
+		for n := d.count(args); n > 0 && d.forward(); n-- {
+		}
+		d.where()
+		break
+	case "rs", "reverse-step":
+		for n := d.count(args); n > 0 && d.backward(); n-- {
+		}
+		d.where()
+		break
+	case "c", "continue":
+		d.cont(d.forward)
+		d.where()
+		break
+	case "rc", "reverse-continue":
+		d.cont(d.backward)
+		d.where()
+		break
+	case "b", "break":
+		if n := d.number(args); n > 0 {
+			d.breakpoints[n] = true
+			d.printBreakpoints()
+		}
+		break
+	case "d", "delete":
+		if n := d.number(args); n > 0 {
+			delete(d.breakpoints, n)
+			d.printBreakpoints()
+		}
+		break
+	case "p", "print":
+		if len(args) == 0 {
+			d.where()
+			fmt.Fprintf(d.out, "pc %d, rc %d\n", d.vm.pc, d.vm.rc)
+			for i, v := range d.vm.mem {
+				if v != 0 {
+					fmt.Fprintf(d.out, "$%d = %d\n", i, v)
+				}
+			}
+			break
+		}
+		if n := d.slot(args); n >= 0 {
+			fmt.Fprintf(d.out, "$%d = %d\n", n, d.vm.mem[n])
+		}
+		break
+	case "last":
+		n := d.slot(args)
+		if n < 0 {
+			break
+		}
+		step, s, old := d.vm.lastChange(n)
+		if step == 0 {
+			i18n.Fprintf(d.out, I18N_DEBUG_NEVER_CHANGED, n)
+			break
+		}
+		inst := d.vm.prog.instructions[s.pc]
+		i18n.Fprintf(d.out, I18N_DEBUG_CHANGED, n, step, inst.line, instructionText(inst), old, d.vm.mem[n])
+		break
+	case "o", "output":
+		for _, r := range d.vm.results {
+			fmt.Fprintln(d.out, r.ToString())
+		}
+		break
+	default:
+		fmt.Fprintln(d.out, i18n.Sprintf(I18N_DEBUG_HELP))
+		break
+	}
+	return true
+}
+
+// runDebug the `debug` command, read the commands from the standard input
+func runDebug(args []string) int {
+	flags := flag.NewFlagSet("debug", flag.ExitOnError)
+	optimized := flags.Bool("O", false, i18n.Sprintf(I18N_FLAG_OPTIMIZE))
+	maxSteps := flags.Int("max-steps", GOLDEN_MAX_STEPS, i18n.Sprintf(I18N_FLAG_MAX_STEPS))
+	flags.Parse(args)
+
+	if flags.NArg() == 0 || flags.NArg() > 2 {
+		i18n.Println(I18N_ERR_PROG_TOO_MANY_PARMS)
+		return 1
+	}
+	if !isProgramFile(flags.Arg(0)) {
+		i18n.Println(I18N_ERR_PROG_NEED_ASM_EXT)
+		return 1
+	}
+
+	prog, err := loadProgram(flags.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if *optimized {
+		*prog = optimize(*prog)
+	}
+	input, err := readInput(flags.Arg(1))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	d := newDebugger(*prog, input, *maxSteps, os.Stdout)
+	d.where()
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Print(DEBUG_PROMPT)
+		if !scanner.Scan() || !d.command(scanner.Text()) {
+			break
+		}
+	}
+	return 0
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// testValue a value checked by the tests, `name` says which one failed
+type testValue struct {
+	name     string
+	expected int64
+	received int64
+}
+
+func checkValues(t *testing.T, values []testValue) {
+	t.Helper()
+	for _, v := range values {
+		if v.received != v.expected {
+			t.Errorf("%s\nExpected: '%v'\nReceived: '%v'", v.name, v.expected, v.received)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	prog, err := compile("read $0\n$1 = 1\n$1 = 1\n$2 = $0 * 2\n$1 = $2 + 1\nwrite $1\nread &0\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm := newVM(*prog, []int64{7})
+	vm.history = &History{}
+	vm.costs = &DEFAULT_COSTS
+	if err := vm.run(); err == nil {
+		t.Fatalf("\nExpected: an error\nReceived: '%v'", err)
+	}
+	mem := append([]int64{}, vm.mem...)
+
+	step, s, old := vm.lastChange(1)
+	checkValues(t, []testValue{{"step", 5, int64(step)}, {"pc", 4, int64(s.pc)}, {"old", 1, old}})
+	if step, _, _ := vm.lastChange(3); step != 0 {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", 0, step)
+	}
+
+	for i := 0; i < 3; i++ {
+		vm.back()
+	}
+	checkValues(t, []testValue{
+		{"steps", 4, int64(vm.steps)},
+		{"pc", 4, int64(vm.pc)},
+		{"$1", 1, vm.mem[1]},
+		{"results", 0, int64(len(vm.results))},
+		{"rc", 1, int64(vm.rc)},
+	})
+	// the step 3 writes the same value
+	if step, _, _ := vm.lastChange(1); step != 2 {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", 2, step)
+	}
+
+	for vm.back() {
+	}
+	checkValues(t, []testValue{
+		{"steps", 0, int64(vm.steps)},
+		{"pc", 0, int64(vm.pc)},
+		{"rc", 0, int64(vm.rc)},
+		{"cycles", 0, vm.cycles},
+		{"$0", 0, vm.mem[0]},
+		{"$2", 0, vm.mem[2]},
+	})
+
+	vm.run()
+	for i, v := range vm.mem {
+		if v != mem[i] {
+			t.Errorf("$%d\nExpected: '%v'\nReceived: '%v'", i, mem[i], v)
+		}
+	}
+}
+
+func TestDebugger(t *testing.T) {
+	prog, err := compile("$0 = 100\nloop:\nread &0 end\n$0 = $0 + 1\nto loop\nend:\nwrite $0\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	d := newDebugger(*prog, []int64{5, 6}, 0, &out)
+
+	commands := []struct {
+		command  string
+		expected string
+	}{
+		{"b 3", "pontos de parada: 3\n"},
+		{"c", "passo 1, linha 3: read &0 end\n"},
+		{"c", "passo 4, linha 3: read &0 end\n"},
+		{"last $0", "$0 mudou no passo 3, linha 4: $0 = $0 + 1, de 100 para 101\n"},
+		{"s 2", "passo 6, linha 5: to loop\n"},
+		{"rc", "passo 4, linha 3: read &0 end\n"},
+		{"rs 2", "passo 2, linha 4: $0 = $0 + 1\n"},
+		{"p $101", "$101 = 0\n"},
+		{"d 3", "pontos de parada: \n"},
+		{"c", "passo 9, o programa terminou\n"},
+		{"o", "$ [ 0 ] 102\n"},
+		{"last $7", "$7 nunca mudou\n"},
+		{"b x", "esperando um número positivo, mas recebeu 'x'\n"},
+	}
+	for _, c := range commands {
+		out.Reset()
+		if running := d.command(c.command); !running {
+			t.Fatalf("%s\nExpected: '%v'\nReceived: '%v'", c.command, true, running)
+		}
+		if out.String() != c.expected {
+			t.Errorf("%s\nExpected: '%v'\nReceived: '%v'", c.command, c.expected, out.String())
+		}
+	}
+
+	out.Reset()
+	d.command("help")
+	if !strings.Contains(out.String(), "rc continua para trás") {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", "rc continua para trás", out.String())
+	}
+	if running := d.command("q"); running {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", false, running)
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	prog, err := compile("$0 = $0 + 1\n$0 = $0 + 1\n$0 = $0 + 1\n$0 = $0 + 1\n$0 = $0 + 1\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm := newVM(*prog, nil)
+	vm.history = newHistory(3)
+	if err := vm.run(); err != nil {
+		t.Fatal(err)
+	}
+	step, _, old := vm.lastChange(0)
+	checkValues(t, []testValue{{"step", 5, int64(step)}, {"old", 4, old}})
+	for vm.back() {
+	}
+	checkValues(t, []testValue{{"steps", 2, int64(vm.steps)}, {"pc", 2, int64(vm.pc)}, {"$0", 2, vm.mem[0]}})
+	vm.run()
+	step, _, old = vm.lastChange(0)
+	checkValues(t, []testValue{{"step", 5, int64(step)}, {"old", 4, old}, {"history", 3, int64(vm.history.size)}})
+}
+
+func TestDebuggerEndless(t *testing.T) {
+	prog, err := compile("aa:\nto aa\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	d := newDebugger(*prog, nil, 1000, &out)
+	d.command("c")
+	expected := "passo 1.000, [Execution error: line 2] <[limit]> too many instructions executed, the limit is: 1000.\n"
+	if out.String() != expected {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", expected, out.String())
+	}
+}
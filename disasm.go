@@ -0,0 +1,226 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const LISTING_EXT = ".lst"
+
+var OPERATOR_TEXT = map[int]string{
+	OP_SUB: "-",
+	OP_ADD: "+",
+	OP_MUL: "*",
+	OP_DIV: "/",
+}
+
+var COMPARISON_TEXT = map[int64]string{
+	COMP_EQ: "==",
+	COMP_DF: "!=",
+	COMP_GT: ">",
+	COMP_LT: "<",
+	COMP_GE: ">=",
+	COMP_LE: "<=",
+}
+
+var LOGIC_OPERATOR_TEXT = map[int64]string{
+	LOP_AND: "&&",
+	LOP_OR:  "||",
+}
+
+// valueText the canonical text of a constant|variable|reference
+func valueText(v InstValue) string {
+	switch v.typ {
+	case VAL_CONST:
+		return strconv.FormatInt(v.val, 10)
+	case VAL_VAR:
+		return fmt.Sprintf("$%d", v.val)
+	case VAL_REF:
+		return fmt.Sprintf("&%d", v.val)
+	}
+	panic("IMPOSSIBLE")
+}
+
+// conditionText the canonical text of a condition compiled by `compileIf`, without the `if`
+func conditionText(moveIf []interface{}) string {
+	parts := make([]string, len(moveIf))
+	for i, p := range moveIf {
+		switch ifInstOrder(i) {
+		case IFO_VAL:
+			parts[i] = valueText(p.(InstValue))
+			break
+		case IFO_CMP:
+			parts[i] = COMPARISON_TEXT[p.(int64)]
+			break
+		case IFO_LOP:
+			parts[i] = LOGIC_OPERATOR_TEXT[p.(int64)]
+			break
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// instructionText the canonical text of an instruction, always with the english keywords
+func instructionText(inst Instruction) string {
+	kw := KEYWORDS[LANG_EN]
+	switch inst.typ {
+	case INST_OP:
+		op := inst.val.(Operation)
+		if op.op == OP_UNI {
+			return fmt.Sprintf("%s = %s", valueText(op.v), valueText(op.v1))
+		}
+		return fmt.Sprintf("%s = %s %s %s", valueText(op.v), valueText(op.v1), OPERATOR_TEXT[op.op], valueText(op.v2))
+	case INST_TO:
+		i := inst.val.(IfInst)
+		if i.moveIf == nil {
+			return fmt.Sprintf("%s %s", kw.to, i.target)
+		}
+		return fmt.Sprintf("%s %s %s %s", kw.to, i.target, kw.iff, conditionText(i.moveIf))
+	case INST_WRITE:
+		return fmt.Sprintf("%s %s", kw.write, valueText(inst.val.(InstValue)))
+	case INST_READ:
+		r := inst.val.(ReadInst)
+		if r.elseLabel == "" {
+			return fmt.Sprintf("%s %s", kw.read, valueText(r.target))
+		}
+		return fmt.Sprintf("%s %s %s", kw.read, valueText(r.target), r.elseLabel)
+	case INST_ASSERT:
+		a := inst.val.(AssertInst)
+		if a.message == "" {
+			return fmt.Sprintf("%s %s", kw.assert, conditionText(a.cond))
+		}
+		return fmt.Sprintf("%s %s %s", kw.assert, conditionText(a.cond), strconv.Quote(a.message))
+	case INST_SYS:
+		s := inst.val.(SysInst)
+		parts := []string{kw.sys, s.name}
+		for _, arg := range s.args {
+			parts = append(parts, valueText(arg))
+		}
+		return strings.Join(parts, " ")
+	}
+	panic("IMPOSSIBLE")
+}
+
+// labelsAt the label names of every instruction index, sorted by name
+func labelsAt(prog Program) map[int][]string {
+	at := make(map[int][]string)
+	for _, name := range sortedLabels(prog) {
+		at[prog.labels[name]] = append(at[prog.labels[name]], name)
+	}
+	return at
+}
+
+// disassemble render the program as a listing with the instruction index, the source line and the canonical text,
+// jumps are annotated with the label name and index
+func disassemble(prog Program) string {
+	var sb strings.Builder
+	at := labelsAt(prog)
+
+	sb.WriteString("# index line instruction\n")
+	for i, inst := range prog.instructions {
+		for _, name := range at[i] {
+			sb.WriteString(name + ":\n")
+		}
+
+		text := fmt.Sprintf("%5d %5d  %s", i, inst.line, instructionText(inst))
+		switch inst.typ {
+		case INST_TO:
+			target := inst.val.(IfInst).target
+			text = fmt.Sprintf("%-48s # -> %s@%d", text, target, prog.labels[target])
+			break
+		case INST_READ:
+			if target := inst.val.(ReadInst).elseLabel; target != "" {
+				text = fmt.Sprintf("%-48s # else -> %s@%d", text, target, prog.labels[target])
+			}
+			break
+		}
+		sb.WriteString(text + "\n")
+	}
+	for _, name := range at[len(prog.instructions)] {
+		sb.WriteString(name + ":\n")
+	}
+
+	return sb.String()
+}
+
+// assembleListing the inverse of `disassemble`, keeps the source lines of the listing
+func assembleListing(code string) (*Program, error) {
+	var instructions []Instruction
+	labels := make(map[string]int)
+	kw := KEYWORDS[LANG_EN]
+
+	for iline, line := range strings.Split(code, "\n") {
+		tokens := tokenize(strings.TrimSpace(line))
+		if isCommentInst(tokens) {
+			continue
+		}
+		if label, exists := hasLabel(tokens); exists {
+			labels[label] = len(instructions)
+			continue
+		}
+
+		if len(tokens) < 3 {
+			return nil, compilationError(iline+1, formatError("listing", I18N_LISTING_ERR_INVALID_LINE, tokens))
+		}
+		index, err := strconv.Atoi(tokens[0])
+		if err != nil || index != len(instructions) {
+			return nil, compilationError(iline+1, formatError("listing", I18N_LISTING_ERR_INVALID_INDEX, tokens[0]))
+		}
+		sline, err := strconv.Atoi(tokens[1])
+		if err != nil {
+			return nil, compilationError(iline+1, formatError("listing", I18N_LISTING_ERR_INVALID_LINE, tokens[1]))
+		}
+
+		hasError := true
+		for _, f := range INSTRUCTIONS {
+			inst, err := f(kw, tokens[2:])
+			if err != nil {
+				return nil, compilationError(iline+1, err)
+			}
+			if inst != nil {
+				hasError = false
+				inst.line = sline
+				instructions = append(instructions, *inst)
+				break
+			}
+		}
+		if hasError {
+			return nil, compilationError(iline+1, formatError("?", I18N_COMPILE_ERR_INST_NOT_FOUND, tokens[2:]))
+		}
+	}
+
+	if err := checkLabels(instructions, labels); err != nil {
+		return nil, err
+	}
+
+	return &Program{instructions: instructions, labels: labels}, nil
+}
+
+// runDisasm the `disasm` command, print the listing of a source, bytecode or listing file
+func runDisasm(args []string) int {
+	flags := flag.NewFlagSet("disasm", flag.ExitOnError)
+	optimized := flags.Bool("O", false, i18n.Sprintf(I18N_FLAG_OPTIMIZE))
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		i18n.Println(I18N_ERR_PROG_TOO_MANY_PARMS)
+		return 1
+	}
+	if !isProgramFile(flags.Arg(0)) {
+		i18n.Println(I18N_ERR_PROG_NEED_ASM_EXT)
+		return 1
+	}
+
+	prog, err := loadProgram(flags.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if *optimized {
+		*prog = optimize(*prog)
+	}
+	fmt.Print(disassemble(*prog))
+	return 0
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDisassembleRoundTrip(t *testing.T) {
+	items, err := os.ReadDir(EXAMPLE_FILENAME)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, item := range items {
+		if strings.HasSuffix(item.Name(), ".asm") {
+			prog, err := loadProgram(path.Join(EXAMPLE_FILENAME, item.Name()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			listing := disassemble(*prog)
+			assembled, err := assembleListing(listing)
+			if err != nil {
+				t.Errorf("%v: %v", item.Name(), err)
+				continue
+			}
+			if !reflect.DeepEqual(prog, assembled) {
+				t.Errorf("\nExpected: '%v'\nReceived: '%v'", prog, assembled)
+			}
+			if again := disassemble(*assembled); again != listing {
+				t.Errorf("\nExpected: '%v'\nReceived: '%v'", listing, again)
+			}
+		}
+	}
+}
@@ -0,0 +1,395 @@
+package main
+
+const (
+	ENGINE_SWITCH  = "switch"
+	ENGINE_CLOSURE = "closure"
+)
+
+// operand a value resolved ahead of time, constants are stored after the memory so a constant
+// and a variable are both read with `r[slot]`
+type operand struct {
+	slot int64
+	ref  bool
+}
+
+type closureInst func(vm *VM, r []int64) error
+
+// ClosureProgram a program compiled into one specialized closure per instruction
+type ClosureProgram struct {
+	prog   Program
+	consts []int64
+	code   []closureInst
+}
+
+// load read an operand, a reference is checked like `valueFromMem`
+func (o operand) load(r []int64) (int64, error) {
+	if !o.ref {
+		return r[o.slot], nil
+	}
+	addr := r[o.slot]
+	if addr < 0 || addr >= MEMORY_SIZE {
+		return 0, formatError("[memory]", I18N_EXEC_ERR_INVALID_MEMORY_ACCESS, o.slot)
+	}
+	return r[addr], nil
+}
+
+// address the slot written by an operand, a reference is checked like `addressFromMem`
+func (o operand) address(r []int64) (int64, error) {
+	if !o.ref {
+		return o.slot, nil
+	}
+	addr := r[o.slot]
+	if addr < 0 || addr >= MEMORY_SIZE {
+		return 0, formatError("[memory]", I18N_EXEC_ERR_INVALID_MEMORY_ACCESS, o.slot)
+	}
+	return addr, nil
+}
+
+func applyOperation(op int, v1 int64, v2 int64) int64 {
+	switch op {
+	case OP_ADD:
+		return v1 + v2
+	case OP_SUB:
+		return v1 - v2
+	case OP_MUL:
+		return v1 * v2
+	case OP_DIV:
+		return v1 / v2
+	}
+	return v1
+}
+
+func applyComparison(cp int64, v1 int64, v2 int64) bool {
+	switch cp {
+	case COMP_EQ:
+		return v1 == v2
+	case COMP_DF:
+		return v1 != v2
+	case COMP_GT:
+		return v1 > v2
+	case COMP_LT:
+		return v1 < v2
+	case COMP_GE:
+		return v1 >= v2
+	case COMP_LE:
+		return v1 <= v2
+	}
+	panic("IMPOSSIBLE")
+}
+
+type closureCompiler struct {
+	prog   Program
+	consts map[int64]int64
+	list   []int64
+}
+
+func (c *closureCompiler) operand(v InstValue) operand {
+	switch v.typ {
+	case VAL_CONST:
+		slot, ok := c.consts[v.val]
+		if !ok {
+			slot = int64(MEMORY_SIZE + len(c.list))
+			c.consts[v.val] = slot
+			c.list = append(c.list, v.val)
+		}
+		return operand{slot: slot}
+	case VAL_VAR:
+		return operand{slot: v.val}
+	case VAL_REF:
+		return operand{slot: v.val, ref: true}
+	}
+	panic("IMPOSSIBLE")
+}
+
+func (c *closureCompiler) operation(inst Instruction, next int) closureInst {
+	op := inst.val.(Operation)
+	a, x, y := c.operand(op.v), c.operand(op.v1), c.operand(op.v2)
+
+	if !a.ref && !x.ref && !y.ref {
+		a, x, y := a.slot, x.slot, y.slot
+		switch op.op {
+		case OP_UNI:
+			return func(vm *VM, r []int64) error {
+				r[a] = r[x]
+				vm.pc = next
+				return nil
+			}
+		case OP_ADD:
+			return func(vm *VM, r []int64) error {
+				r[a] = r[x] + r[y]
+				vm.pc = next
+				return nil
+			}
+		case OP_SUB:
+			return func(vm *VM, r []int64) error {
+				r[a] = r[x] - r[y]
+				vm.pc = next
+				return nil
+			}
+		case OP_MUL:
+			return func(vm *VM, r []int64) error {
+				r[a] = r[x] * r[y]
+				vm.pc = next
+				return nil
+			}
+		case OP_DIV:
+			line := inst.line
+			return func(vm *VM, r []int64) error {
+				if r[y] == 0 {
+					return divisionByZeroError(line)
+				}
+				r[a] = r[x] / r[y]
+				vm.pc = next
+				return nil
+			}
+		}
+	}
+
+	kind := op.op
+	return func(vm *VM, r []int64) error {
+		v1, err := x.load(r)
+		if err != nil {
+			return executionError(inst.line, err)
+		}
+		v2, err := y.load(r)
+		if err != nil {
+			return executionError(inst.line, err)
+		}
+		addr, err := a.address(r)
+		if err != nil {
+			return executionError(inst.line, err)
+		}
+		if kind == OP_DIV && v2 == 0 {
+			return divisionByZeroError(inst.line)
+		}
+		r[addr] = applyOperation(kind, v1, v2)
+		vm.pc = next
+		return nil
+	}
+}
+
+// condition compile a condition, a single comparison without references can't fail
+func (c *closureCompiler) condition(line int, cond []interface{}) func(r []int64) (bool, error) {
+	var values []operand
+	var cmps []int64
+	var lops []int64
+	hasRef := false
+	for i, p := range cond {
+		switch ifInstOrder(i) {
+		case IFO_VAL:
+			values = append(values, c.operand(p.(InstValue)))
+			hasRef = hasRef || values[len(values)-1].ref
+			break
+		case IFO_CMP:
+			cmps = append(cmps, p.(int64))
+			break
+		case IFO_LOP:
+			lops = append(lops, p.(int64))
+			break
+		}
+	}
+
+	if len(cmps) == 1 && !hasRef {
+		x, y := values[0].slot, values[1].slot
+		switch cmps[0] {
+		case COMP_EQ:
+			return func(r []int64) (bool, error) { return r[x] == r[y], nil }
+		case COMP_DF:
+			return func(r []int64) (bool, error) { return r[x] != r[y], nil }
+		case COMP_GT:
+			return func(r []int64) (bool, error) { return r[x] > r[y], nil }
+		case COMP_LT:
+			return func(r []int64) (bool, error) { return r[x] < r[y], nil }
+		case COMP_GE:
+			return func(r []int64) (bool, error) { return r[x] >= r[y], nil }
+		case COMP_LE:
+			return func(r []int64) (bool, error) { return r[x] <= r[y], nil }
+		}
+	}
+
+	// the same evaluation of `evaluateCondition`, every comparison is evaluated
+	return func(r []int64) (bool, error) {
+		res := false
+		for i, cp := range cmps {
+			v1, err := values[2*i].load(r)
+			if err != nil {
+				return false, executionError(line, err)
+			}
+			v2, err := values[2*i+1].load(r)
+			if err != nil {
+				return false, executionError(line, err)
+			}
+			cmp := applyComparison(cp, v1, v2)
+			if i == 0 {
+				res = cmp
+			} else if lops[i-1] == LOP_AND {
+				res = res && cmp
+			} else {
+				res = res || cmp
+			}
+		}
+		return res, nil
+	}
+}
+
+func (c *closureCompiler) jump(inst Instruction, next int) closureInst {
+	to := inst.val.(IfInst)
+	target := c.prog.labels[to.target]
+	if to.moveIf == nil {
+		return func(vm *VM, r []int64) error {
+			vm.pc = target
+			return nil
+		}
+	}
+
+	cond := c.condition(inst.line, to.moveIf)
+	return func(vm *VM, r []int64) error {
+		res, err := cond(r)
+		if err != nil {
+			return err
+		}
+		if res {
+			vm.pc = target
+		} else {
+			vm.pc = next
+		}
+		return nil
+	}
+}
+
+func (c *closureCompiler) assert(inst Instruction, next int) closureInst {
+	cond := c.condition(inst.line, inst.val.(AssertInst).cond)
+	return func(vm *VM, r []int64) error {
+		res, err := cond(r)
+		if err != nil {
+			return err
+		}
+		if !res {
+			return assertionError(vm.mem, inst)
+		}
+		vm.pc = next
+		return nil
+	}
+}
+
+func (c *closureCompiler) write(inst Instruction, next int) closureInst {
+	val := inst.val.(InstValue)
+	switch val.typ {
+	case VAL_CONST:
+		return func(vm *VM, r []int64) error {
+			vm.results = append(vm.results, WriteResult{val: val})
+			vm.pc = next
+			return nil
+		}
+	case VAL_VAR:
+		return func(vm *VM, r []int64) error {
+			vm.results = append(vm.results, WriteResult{val: val, res: r[val.val]})
+			vm.pc = next
+			return nil
+		}
+	}
+	o := c.operand(val)
+	return func(vm *VM, r []int64) error {
+		v, err := o.load(r)
+		if err != nil {
+			return executionError(inst.line, err)
+		}
+		vm.results = append(vm.results, WriteResult{val: val, ref: r[val.val], res: v})
+		vm.pc = next
+		return nil
+	}
+}
+
+func (c *closureCompiler) read(inst Instruction, next int) closureInst {
+	in := inst.val.(ReadInst)
+	target := c.operand(in.target)
+	elseLabel := -1
+	if in.elseLabel != "" {
+		elseLabel = c.prog.labels[in.elseLabel]
+	}
+	return func(vm *VM, r []int64) error {
+		if vm.rc < len(vm.input) {
+			addr, err := target.address(r)
+			if err != nil {
+				return executionError(inst.line, err)
+			}
+			r[addr] = vm.input[vm.rc]
+			vm.rc += 1
+			vm.pc = next
+			return nil
+		}
+		if elseLabel < 0 {
+			return executionError(inst.line, formatError("[read]", I18N_ERR_READ_NOTHING, I18N_ERR_READ_NO_ELSE_LABEL))
+		}
+		vm.pc = elseLabel
+		return nil
+	}
+}
+
+// sys call the handler like the switch engine, the memory of the VM is the start of `r`
+func (c *closureCompiler) sys(inst Instruction, next int) closureInst {
+	return func(vm *VM, r []int64) error {
+		if err := vm.syscall(inst); err != nil {
+			return err
+		}
+		vm.pc = next
+		return nil
+	}
+}
+
+// compileClosures compile every instruction into a closure with the jumps resolved to indexes
+func compileClosures(prog Program) *ClosureProgram {
+	c := closureCompiler{prog: prog, consts: make(map[int64]int64)}
+	code := make([]closureInst, len(prog.instructions))
+	for i, inst := range prog.instructions {
+		switch inst.typ {
+		case INST_OP:
+			code[i] = c.operation(inst, i+1)
+			break
+		case INST_TO:
+			code[i] = c.jump(inst, i+1)
+			break
+		case INST_WRITE:
+			code[i] = c.write(inst, i+1)
+			break
+		case INST_READ:
+			code[i] = c.read(inst, i+1)
+			break
+		case INST_ASSERT:
+			code[i] = c.assert(inst, i+1)
+			break
+		case INST_SYS:
+			code[i] = c.sys(inst, i+1)
+			break
+		}
+	}
+	return &ClosureProgram{prog: prog, consts: c.list, code: code}
+}
+
+// run execute the compiled program, the results are the same of `executeBounded`
+func (cp *ClosureProgram) run(input []int64, maxSteps int) ([]WriteResult, error) {
+	r := make([]int64, MEMORY_SIZE+len(cp.consts))
+	copy(r[MEMORY_SIZE:], cp.consts)
+	vm := newVM(cp.prog, input)
+	vm.mem = r[:MEMORY_SIZE:MEMORY_SIZE]
+
+	code := cp.code
+	for vm.pc < len(code) {
+		if maxSteps > 0 && vm.steps >= maxSteps {
+			return vm.results, stepLimitError(cp.prog.instructions[vm.pc].line, maxSteps)
+		}
+		vm.steps += 1
+		if err := code[vm.pc](vm, r); err != nil {
+			return vm.results, err
+		}
+	}
+	return vm.results, nil
+}
+
+// executeWith execute at most `maxSteps` instructions with the engine chosen by name, 0 is no limit
+func executeWith(engine string, prog Program, input []int64, maxSteps int) ([]WriteResult, error) {
+	if engine == ENGINE_CLOSURE {
+		return compileClosures(prog).run(input, maxSteps)
+	}
+	return executeBounded(prog, input, maxSteps)
+}
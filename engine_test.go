@@ -0,0 +1,30 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClosureEngine(t *testing.T) {
+	for _, c := range transpileCases(t) {
+		prog, err := loadProgram(c.source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		input, err := readInput(c.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, p := range []Program{*prog, optimize(*prog)} {
+			want, wantErr := execute(p, input)
+			got, gotErr := compileClosures(p).run(input, 0)
+			if !reflect.DeepEqual(want, got) {
+				t.Errorf("%s\nExpected: '%v'\nReceived: '%v'", c.name, want, got)
+			}
+			if !reflect.DeepEqual(wantErr, gotErr) {
+				t.Errorf("%s\nExpected: '%v'\nReceived: '%v'", c.name, wantErr, gotErr)
+			}
+		}
+	}
+}
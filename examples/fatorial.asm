@@ -0,0 +1,20 @@
+#lang pt
+para main
+
+
+fatorial:
+  $1 = $1 * $0
+
+  $0 = $0 - 1
+  para fatorial se $0 > 1
+  para fim
+
+
+main:
+  leia $0
+  $1 = 1
+  para fatorial
+
+
+fim:
+  escreva $1
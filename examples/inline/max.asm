@@ -0,0 +1,22 @@
+# Writes the largest of two numbers
+
+#! case first is larger
+#! input: 33 11
+#! expect: $ [ 0 ] 33
+
+#! case second is larger
+#! input: -5 7
+#! expect: $ [ 0 ] 7
+
+#! case missing number
+#! input: 1
+#! expect-error: line 16
+
+read $0
+read $1
+to end if $0 >= $1
+$0 = $1
+
+end:
+  assert $0 >= $1 "the result is the largest"
+  write $0
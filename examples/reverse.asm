@@ -0,0 +1,15 @@
+# write every number of the input in the reverse order
+$0 = 100
+
+read_all:
+  read &0 reverse
+  $0 = $0 + 1
+  to read_all
+
+reverse:
+  $0 = $0 - 1
+  to end if $0 < 100
+  write &0
+  to reverse
+
+end:
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+const FMT_INDENT = "  "
+
+const (
+	FMT_BLANK = iota
+	FMT_COMMENT
+	FMT_PRAGMA
+	FMT_LABEL
+	FMT_INST
+)
+
+type FmtLine struct {
+	typ     int
+	indent  string
+	code    string
+	comment string
+}
+
+// splitComment separate the code tokens from the trailing comment of a line
+func splitComment(line string) ([]string, string) {
+	line = strings.TrimSpace(line)
+	tokens := tokenize(line)
+	pos := 0
+	for i, token := range tokens {
+		pos += strings.Index(line[pos:], token)
+		if strings.HasPrefix(token, "#") {
+			return tokens[:i], strings.TrimRight(line[pos:], " \t\r")
+		}
+		pos += len(token)
+	}
+	return tokens, ""
+}
+
+// parseFmtLine classify a line with the same grammar used by `compile`
+func parseFmtLine(kw Keywords, iline int, line string) (FmtLine, error) {
+	tokens, comment := splitComment(line)
+	if len(tokens) == 0 {
+		if comment == "" {
+			return FmtLine{typ: FMT_BLANK}, nil
+		}
+		if _, exists := hasLangPragma(strings.FieldsFunc(comment, getTokens)); exists {
+			return FmtLine{typ: FMT_PRAGMA, comment: comment}, nil
+		}
+		return FmtLine{typ: FMT_COMMENT, comment: comment}, nil
+	}
+	if label, exists := hasLabel(tokens); exists {
+		return FmtLine{typ: FMT_LABEL, code: label + ":", comment: comment}, nil
+	}
+	for _, f := range INSTRUCTIONS {
+		inst, err := f(kw, tokens)
+		if err != nil {
+			return FmtLine{}, compilationError(iline+1, err)
+		}
+		if inst != nil {
+			return FmtLine{typ: FMT_INST, code: strings.Join(tokens, " "), comment: comment}, nil
+		}
+	}
+	return FmtLine{}, compilationError(iline+1, formatError("?", I18N_COMPILE_ERR_INST_NOT_FOUND, tokens))
+}
+
+// formatSource print labels flush-left, instructions after a label indented and align the trailing comments,
+// every line is kept in place so the formatted program is the same as the original one
+func formatSource(code string) (string, error) {
+	lines := strings.Split(strings.TrimRight(code, " \t\r\n"), "\n")
+	kw, err := getKeywords(lines)
+	if err != nil {
+		return "", err
+	}
+
+	flines := make([]FmtLine, len(lines))
+	indent := ""
+	for iline, line := range lines {
+		flines[iline], err = parseFmtLine(kw, iline, line)
+		if err != nil {
+			return "", err
+		}
+		switch flines[iline].typ {
+		case FMT_LABEL:
+			indent = FMT_INDENT
+			break
+		case FMT_INST:
+			flines[iline].indent = indent
+			break
+		}
+	}
+
+	// comments take the indentation of the code that follows them
+	next := ""
+	for i := len(flines) - 1; i >= 0; i-- {
+		switch flines[i].typ {
+		case FMT_LABEL, FMT_INST:
+			next = flines[i].indent
+			break
+		case FMT_COMMENT:
+			flines[i].indent = next
+			break
+		}
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(flines); {
+		// consecutive lines with trailing comments are aligned together
+		j := i
+		width := 0
+		for j < len(flines) && flines[j].code != "" && flines[j].comment != "" {
+			if w := utf8.RuneCountInString(flines[j].indent + flines[j].code); w > width {
+				width = w
+			}
+			j++
+		}
+		if j == i {
+			l := flines[i]
+			sb.WriteString(strings.TrimRight(l.indent+l.code+l.comment, " "))
+			sb.WriteString("\n")
+			i++
+			continue
+		}
+		for ; i < j; i++ {
+			l := flines[i]
+			sb.WriteString(fmt.Sprintf("%-*s %s\n", width, l.indent+l.code, l.comment))
+		}
+	}
+	formatted := sb.String()
+
+	original, err := compile(code)
+	if err != nil {
+		return "", err
+	}
+	result, err := compile(formatted)
+	if err != nil || !reflect.DeepEqual(original, result) {
+		return "", formatError("fmt", I18N_FMT_ERR_CHANGED, err)
+	}
+
+	return formatted, nil
+}
+
+// runFmt the `fmt` command, print the formatted files or check if they are already formatted
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	check := flags.Bool("check", false, i18n.Sprintf(I18N_FMT_FLAG_CHECK))
+	write := flags.Bool("w", false, i18n.Sprintf(I18N_FMT_FLAG_WRITE))
+	flags.Parse(args)
+
+	status := 0
+	for _, filepath := range flags.Args() {
+		code, err := read(filepath)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		formatted, err := formatSource(code)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+
+		if *check {
+			if formatted != code {
+				i18n.Printf(I18N_FMT_NOT_FORMATTED, filepath)
+				status = 1
+			}
+		} else if *write {
+			if formatted != code {
+				if err := os.WriteFile(filepath, []byte(formatted), 0644); err != nil {
+					fmt.Println(err)
+					return 1
+				}
+			}
+		} else {
+			fmt.Print(formatted)
+		}
+	}
+	return status
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestFormatExamples(t *testing.T) {
+	items, err := os.ReadDir(EXAMPLE_FILENAME)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, item := range items {
+		if strings.HasSuffix(item.Name(), ".asm") {
+			code, err := read(path.Join(EXAMPLE_FILENAME, item.Name()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			formatted, err := formatSource(code)
+			if err != nil {
+				t.Errorf("%v: %v", item.Name(), err)
+				continue
+			}
+			again, err := formatSource(formatted)
+			if err != nil {
+				t.Errorf("%v: %v", item.Name(), err)
+				continue
+			}
+			if formatted != again {
+				t.Errorf("\nExpected: '%v'\nReceived: '%v'", formatted, again)
+			}
+		}
+	}
+}
+
+func TestFormatLayout(t *testing.T) {
+	code := "to  main   # jump\n#c\nmain:\n      $0 = $0   +  1 # inc\n write $0     #out\n\n  #tail\n   end:\n"
+	expected := "to main # jump\n#c\nmain:\n  $0 = $0 + 1 # inc\n  write $0    #out\n\n#tail\nend:\n"
+
+	formatted, err := formatSource(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if formatted != expected {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", expected, formatted)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// FUZZ_MAX_STEPS keeps the programs generated by the fuzzer from running forever
+const FUZZ_MAX_STEPS = 10000
+
+// fuzzSeeds the examples plus lines that used to crash the compiler
+func fuzzSeeds(f *testing.F) []string {
+	items, err := os.ReadDir(EXAMPLE_FILENAME)
+	if err != nil {
+		f.Fatal(err)
+	}
+	var seeds []string
+	for _, item := range items {
+		if strings.HasSuffix(item.Name(), ".asm") {
+			code, err := read(path.Join(EXAMPLE_FILENAME, item.Name()))
+			if err != nil {
+				f.Fatal(err)
+			}
+			seeds = append(seeds, code)
+		}
+	}
+	return append(seeds,
+		"$0 = 1 +", "$0 =", "$0", "write", "read", "to", "to loop if", "assert",
+		"$0 = 1 / 0", "$0 = -1\n&0 = 2", "loop:\nto loop", "assert 1 > 2 \"unterminated",
+		"sys rand $0 1 1000000\nwrite $0",
+	)
+}
+
+func FuzzCompile(f *testing.F) {
+	for _, seed := range fuzzSeeds(f) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, code string) {
+		prog, err := compile(code)
+		if err != nil {
+			return
+		}
+		// what the disassembler prints must assemble into the same program
+		again, err := assembleListing(disassemble(*prog))
+		if err != nil {
+			t.Fatalf("%q: %v", code, err)
+		}
+		if !reflect.DeepEqual(prog.instructions, again.instructions) {
+			t.Fatalf("%q\nExpected: %v\nReceived: %v", code, prog.instructions, again.instructions)
+		}
+	})
+}
+
+func FuzzExecute(f *testing.F) {
+	for _, seed := range fuzzSeeds(f) {
+		f.Add(seed, int64(3), int64(-1))
+	}
+	f.Fuzz(func(t *testing.T, code string, a int64, b int64) {
+		prog, err := compile(code)
+		if err != nil {
+			return
+		}
+		// `sys rand` and `sys time` give each engine a different result
+		for _, inst := range prog.instructions {
+			if inst.typ == INST_SYS {
+				return
+			}
+		}
+		input := []int64{a, b}
+		res, err := executeBounded(*prog, input, FUZZ_MAX_STEPS)
+
+		// both engines must agree on everything, errors included
+		closureRes, closureErr := compileClosures(*prog).run(input, FUZZ_MAX_STEPS)
+		if !reflect.DeepEqual(res, closureRes) {
+			t.Fatalf("%q\nExpected: '%v'\nReceived: '%v'", code, res, closureRes)
+		}
+		if !reflect.DeepEqual(err, closureErr) {
+			t.Fatalf("%q\nExpected: '%v'\nReceived: '%v'", code, err, closureErr)
+		}
+	})
+}
+
+func TestIncompleteInstructions(t *testing.T) {
+	for _, code := range []string{"$0 = 1 +", "$0 =", "write", "read", "to", "assert", "to end if 0\nend:", "read 0"} {
+		if _, err := compile(code); err == nil {
+			t.Errorf("%q\nExpected: an error\nReceived: '%v'", code, err)
+		}
+	}
+}
+
+func TestStepLimit(t *testing.T) {
+	prog, err := compile("loop:\nwrite 1\nto loop\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "[Execution error: line 3] <[limit]> too many instructions executed, the limit is: 5."
+	for _, engine := range []string{ENGINE_SWITCH, ENGINE_CLOSURE} {
+		res, err := executeWith(engine, *prog, nil, 5)
+		if err == nil || err.Error() != expected {
+			t.Errorf("%s\nExpected: '%v'\nReceived: '%v'", engine, expected, err)
+		}
+		if len(res) != 3 {
+			t.Errorf("%s\nExpected: '%v'\nReceived: '%v'", engine, 3, len(res))
+		}
+	}
+}
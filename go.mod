@@ -1,5 +1,11 @@
 module github.com/lelaut/fasm
 
-go 1.17
+go 1.18
 
-require golang.org/x/text v0.3.7 // indirect
+require (
+	golang.org/x/term v0.1.0
+	golang.org/x/text v0.3.7
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/sys v0.0.0-20210615035016-665e8c7367d1 // indirect
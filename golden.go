@@ -0,0 +1,420 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	GOLDEN_INPUT_EXT  = ".in"
+	GOLDEN_OUTPUT_EXT = ".out"
+
+	// GOLDEN_MAX_STEPS stops a program that never ends, so one case can't block the others
+	GOLDEN_MAX_STEPS = 10000000
+)
+
+// The inline expectations are comments that `compile` ignores, each `#! case` starts a new case:
+//
+//	#! case two numbers
+//	#! input: 11 22
+//	#! expect: $ [ 0 ] 11
+//	#! expect-error: line 4
+const (
+	INLINE_PREFIX       = "#!"
+	INLINE_CASE         = "case"
+	INLINE_INPUT        = "input:"
+	INLINE_EXPECT       = "expect:"
+	INLINE_EXPECT_ERROR = "expect-error:"
+)
+
+// GoldenCase a program with the output it must print, the input is optional
+type GoldenCase struct {
+	name   string
+	source string
+	input  string
+	output string
+	// inline the case comes from `#!` comments instead of the side files
+	inline *InlineCase
+}
+
+// InlineCase the input and expected output written inside the source
+type InlineCase struct {
+	input  []int64
+	expect []string
+	// expectError empty when the program must not fail, `line N` matches the line of the error
+	// and anything else must be part of the error message
+	expectError string
+}
+
+var lineExpectation = regexp.MustCompile(`^line (\d+)$`)
+
+// matchError if the error is the one expected by an `expect-error`
+func matchError(expected string, err error) bool {
+	if m := lineExpectation.FindStringSubmatch(expected); m != nil {
+		var lerr *LineError
+		return errors.As(err, &lerr) && strconv.Itoa(lerr.line) == m[1]
+	}
+	return strings.Contains(err.Error(), expected)
+}
+
+// parseInlineCases the cases written with `#!` comments in the source, the lines before the first
+// `#! case` make a case named after the file
+func parseInlineCases(name string, source string, code string) ([]GoldenCase, error) {
+	var cases []GoldenCase
+	current := GoldenCase{name: name, source: source, inline: &InlineCase{}}
+	used := false
+	for iline, line := range strings.Split(code, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, INLINE_PREFIX) {
+			continue
+		}
+		directive := strings.TrimSpace(strings.TrimPrefix(line, INLINE_PREFIX))
+		fields := strings.Fields(directive)
+		if len(fields) == 0 {
+			return nil, compilationError(iline+1, formatError("#!", I18N_TEST_ERR_DIRECTIVE, line))
+		}
+		value := strings.TrimSpace(strings.TrimPrefix(directive, fields[0]))
+
+		switch fields[0] {
+		case INLINE_CASE:
+			if used {
+				cases = append(cases, current)
+			}
+			caseName := name
+			if value != "" {
+				caseName = name + "#" + value
+			}
+			current = GoldenCase{name: caseName, source: source, inline: &InlineCase{}}
+			break
+		case INLINE_INPUT:
+			for _, v := range strings.Fields(value) {
+				n, err := strconv.ParseInt(v, 10, 64)
+				if err != nil {
+					return nil, compilationError(iline+1, formatError("#!", I18N_TEST_ERR_INPUT, v))
+				}
+				current.inline.input = append(current.inline.input, n)
+			}
+			break
+		case INLINE_EXPECT:
+			current.inline.expect = append(current.inline.expect, value)
+			break
+		case INLINE_EXPECT_ERROR:
+			if value == "" {
+				return nil, compilationError(iline+1, formatError("#!", I18N_TEST_ERR_DIRECTIVE, line))
+			}
+			current.inline.expectError = value
+			break
+		default:
+			return nil, compilationError(iline+1, formatError("#!", I18N_TEST_ERR_DIRECTIVE, line))
+		}
+		used = true
+	}
+	if used {
+		cases = append(cases, current)
+	}
+	return cases, nil
+}
+
+// CaseResult the outcome of running a golden case
+type CaseResult struct {
+	c        GoldenCase
+	expected []string
+	received []string
+	// err a problem to run the case at all, like a missing file
+	err     error
+	elapsed time.Duration
+}
+
+func (r CaseResult) passed() bool {
+	if r.err != nil || len(r.expected) != len(r.received) {
+		return false
+	}
+	for i := range r.expected {
+		if r.expected[i] != r.received[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// findGoldenCases every `.asm` under the directory with an `.asm.out` beside it, plus the inline cases
+func findGoldenCases(dir string) ([]GoldenCase, error) {
+	var cases []GoldenCase
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".asm") {
+			return nil
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			name = path
+		}
+		name = filepath.ToSlash(name)
+
+		if _, err := os.Stat(path + GOLDEN_OUTPUT_EXT); err == nil {
+			input := path + GOLDEN_INPUT_EXT
+			if _, err := os.Stat(input); err != nil {
+				input = ""
+			}
+			cases = append(cases, GoldenCase{name: name, source: path, input: input, output: path + GOLDEN_OUTPUT_EXT})
+		}
+
+		code, err := read(path)
+		if err != nil {
+			return err
+		}
+		inline, err := parseInlineCases(name, path, code)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		cases = append(cases, inline...)
+		return nil
+	})
+	return cases, err
+}
+
+// outputLines the lines of an output, each one without the spaces around and without the empty lines at the end
+func outputLines(out string) []string {
+	lines := strings.Split(out, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSpace(lines[i])
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// caseInput the input of the case, from the comments or from the side file
+func caseInput(c GoldenCase) ([]int64, error) {
+	if c.inline != nil {
+		return c.inline.input, nil
+	}
+	return readInput(c.input)
+}
+
+// runGoldenCase execute the case, what is received is the same that `fasm run` prints
+func runGoldenCase(c GoldenCase, maxSteps int) (res CaseResult) {
+	start := time.Now()
+	res.c = c
+	defer func() {
+		res.elapsed = time.Since(start)
+	}()
+
+	expectError := ""
+	if c.inline != nil {
+		res.expected = c.inline.expect
+		expectError = c.inline.expectError
+	} else {
+		dat, err := read(c.output)
+		if err != nil {
+			res.err = err
+			return res
+		}
+		res.expected = outputLines(dat)
+	}
+	input, err := caseInput(c)
+	if err != nil {
+		res.err = err
+		return res
+	}
+
+	prog, err := loadProgram(c.source)
+	if err == nil {
+		var results []WriteResult
+		results, err = executeBounded(*prog, input, maxSteps)
+		for _, r := range results {
+			res.received = append(res.received, r.ToString())
+		}
+	}
+
+	// a matching error isn't part of the output, anything else shows up in the diff
+	if expectError != "" && (err == nil || !matchError(expectError, err)) {
+		res.expected = append(res.expected, INLINE_EXPECT_ERROR+" "+expectError)
+	}
+	if err != nil && (expectError == "" || !matchError(expectError, err)) {
+		res.received = append(res.received, err.Error())
+	}
+	return res
+}
+
+// runGoldenCases run the cases with `jobs` at the same time, the results keep the order of the cases
+func runGoldenCases(cases []GoldenCase, jobs int, maxSteps int) []CaseResult {
+	if jobs < 1 {
+		jobs = 1
+	}
+	results := make([]CaseResult, len(cases))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for j := 0; j < jobs; j++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = runGoldenCase(cases[i], maxSteps)
+			}
+		}()
+	}
+	for i := range cases {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return results
+}
+
+// lineDiff the lines removed from the expected output with `-` and the lines added with `+`
+func lineDiff(expected []string, received []string) string {
+	// lcs[i][j] the longest common subsequence of expected[i:] and received[j:]
+	lcs := make([][]int, len(expected)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(received)+1)
+	}
+	for i := len(expected) - 1; i >= 0; i-- {
+		for j := len(received) - 1; j >= 0; j-- {
+			if expected[i] == received[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var sb strings.Builder
+	i, j := 0, 0
+	for i < len(expected) || j < len(received) {
+		switch {
+		case i < len(expected) && j < len(received) && expected[i] == received[j]:
+			sb.WriteString("  " + expected[i] + "\n")
+			i++
+			j++
+		case j >= len(received) || (i < len(expected) && lcs[i+1][j] >= lcs[i][j+1]):
+			sb.WriteString("- " + expected[i] + "\n")
+			i++
+		default:
+			sb.WriteString("+ " + received[j] + "\n")
+			j++
+		}
+	}
+	return sb.String()
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitSuite struct {
+	XMLName  xml.Name    `xml:"testsuite"`
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// junitXML the report of the results in the JUnit XML format
+func junitXML(results []CaseResult, elapsed time.Duration) ([]byte, error) {
+	suite := junitSuite{Name: "fasm", Tests: len(results), Time: junitSeconds(elapsed)}
+	for _, r := range results {
+		c := junitCase{Name: r.c.name, Classname: "fasm", Time: junitSeconds(r.elapsed)}
+		if r.err != nil {
+			c.Failure = &junitFailure{Message: r.err.Error()}
+		} else if !r.passed() {
+			c.Failure = &junitFailure{Message: i18n.Sprintf(I18N_TEST_ERR_OUTPUT), Text: lineDiff(r.expected, r.received)}
+		}
+		if c.Failure != nil {
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	dat, err := xml.MarshalIndent(suite, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(dat, '\n')...), nil
+}
+
+// runTest the `test` command, check every program of the directories against its golden output
+func runTest(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	jobs := flags.Int("j", runtime.NumCPU(), i18n.Sprintf(I18N_TEST_FLAG_JOBS))
+	junit := flags.String("junit", "", i18n.Sprintf(I18N_TEST_FLAG_JUNIT))
+	maxSteps := flags.Int("max-steps", GOLDEN_MAX_STEPS, i18n.Sprintf(I18N_FLAG_MAX_STEPS))
+	flags.Parse(args)
+
+	dirs := flags.Args()
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+	var cases []GoldenCase
+	for _, dir := range dirs {
+		found, err := findGoldenCases(dir)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		cases = append(cases, found...)
+	}
+
+	start := time.Now()
+	results := runGoldenCases(cases, *jobs, *maxSteps)
+	elapsed := time.Since(start)
+
+	failed := 0
+	for _, r := range results {
+		if r.passed() {
+			i18n.Printf(I18N_TEST_PASS, r.c.name, r.elapsed)
+			continue
+		}
+		failed++
+		i18n.Printf(I18N_TEST_FAIL, r.c.name, r.elapsed)
+		if r.err != nil {
+			fmt.Println(r.err)
+		} else {
+			fmt.Print(lineDiff(r.expected, r.received))
+		}
+	}
+	i18n.Printf(I18N_TEST_SUMMARY, len(results)-failed, failed, elapsed)
+
+	if *junit != "" {
+		dat, err := junitXML(results, elapsed)
+		if err == nil {
+			err = os.WriteFile(*junit, dat, 0644)
+		}
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
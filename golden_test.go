@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLineDiff(t *testing.T) {
+	expected := []string{"$ 1", "$ 2", "$ 3"}
+	received := []string{"$ 1", "$ 3", "$ 4"}
+	want := "  $ 1\n- $ 2\n  $ 3\n+ $ 4\n"
+	if diff := lineDiff(expected, received); diff != want {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", want, diff)
+	}
+}
+
+func TestGoldenExamples(t *testing.T) {
+	cases, err := findGoldenCases(EXAMPLE_FILENAME)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range runGoldenCases(cases, len(cases), GOLDEN_MAX_STEPS) {
+		if r.err != nil {
+			t.Errorf("%v: %v", r.c.name, r.err)
+		} else if !r.passed() {
+			t.Errorf("%v\n%v", r.c.name, lineDiff(r.expected, r.received))
+		}
+	}
+}
+
+func TestGoldenRunner(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"ok.asm":            "read $0\nwrite $0\n",
+		"ok.asm.in":         "7",
+		"ok.asm.out":        "$ [ 0 ] 7\n",
+		"sub/short.asm":     "write 1\n",
+		"sub/short.asm.out": "$ 1\n$ 2\n",
+		"sub/error.asm":     "$0 = 5000\nwrite &0\n",
+		"sub/error.asm.out": "$ 1\n",
+		"no_output.asm":     "write 1\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases, err := findGoldenCases(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) != 3 {
+		t.Fatalf("\nExpected: '%v'\nReceived: '%v'", 3, len(cases))
+	}
+
+	results := runGoldenCases(cases, 2, GOLDEN_MAX_STEPS)
+	passed := map[string]bool{}
+	for _, r := range results {
+		passed[r.c.name] = r.passed()
+	}
+	want := map[string]bool{"ok.asm": true, "sub/short.asm": false, "sub/error.asm": false}
+	if !reflect.DeepEqual(passed, want) {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", want, passed)
+	}
+
+	dat, err := junitXML(results, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var suite junitSuite
+	if err := xml.Unmarshal(dat, &suite); err != nil {
+		t.Fatal(err)
+	}
+	if received := []int{suite.Tests, suite.Failures, len(suite.Cases)}; !reflect.DeepEqual(received, []int{3, 2, 3}) {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", []int{3, 2, 3}, received)
+	}
+}
+
+func TestInlineCases(t *testing.T) {
+	code := `#! input: 1 2
+#! expect: $ [ 0 ] 1
+read $0
+write $0
+#! case empty
+#! expect-error: line 3
+#! case bad input
+#! input: 5
+#! expect-error: no more input
+`
+	cases, err := parseInlineCases("p.asm", "p.asm", code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"p.asm", "p.asm#empty", "p.asm#bad input"}
+	if len(cases) != len(names) {
+		t.Fatalf("\nExpected: '%v'\nReceived: '%v'", len(names), len(cases))
+	}
+	for i, c := range cases {
+		if c.name != names[i] {
+			t.Errorf("\nExpected: '%v'\nReceived: '%v'", names[i], c.name)
+		}
+	}
+	if input := []int64{1, 2}; !reflect.DeepEqual(cases[0].inline.input, input) {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", input, cases[0].inline.input)
+	}
+	if expect := []string{"$ [ 0 ] 1"}; !reflect.DeepEqual(cases[0].inline.expect, expect) {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", expect, cases[0].inline.expect)
+	}
+
+	prog, err := compile(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = execute(*prog, nil)
+	if err == nil || !matchError("line 3", err) {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", "line 3", err)
+	}
+	if matchError("line 4", err) {
+		t.Errorf("%v matches line 4", err)
+	}
+
+	for _, bad := range []string{"#! input: x", "#! expect-error:", "#! unknown", "#!"} {
+		if _, err := parseInlineCases("p.asm", "p.asm", bad); err == nil {
+			t.Errorf("%q\nExpected: an error\nReceived: '%v'", bad, err)
+		}
+	}
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	GRADE_TIMEOUT = 5 * time.Second
+	// GRADE_CLOCK_STEPS how many instructions are executed between two checks of the timeout
+	GRADE_CLOCK_STEPS = 1024
+)
+
+// Rubric the cases of an exercise, the limits are the default of the cases without their own
+type Rubric struct {
+	Name     string       `json:"name" yaml:"name"`
+	MaxSteps int          `json:"max_steps" yaml:"max_steps"`
+	Timeout  string       `json:"timeout" yaml:"timeout"`
+	Costs    CostTable    `json:"costs" yaml:"costs"`
+	Cases    []RubricCase `json:"cases" yaml:"cases"`
+}
+
+// RubricCase the input and the output expected, `expect` has the lines that `fasm run` prints
+// and `expect_error` follows the rules of the `#! expect-error:` comments
+type RubricCase struct {
+	Name        string   `json:"name" yaml:"name"`
+	Input       []int64  `json:"input" yaml:"input"`
+	Expect      []string `json:"expect" yaml:"expect"`
+	ExpectError string   `json:"expect_error" yaml:"expect_error"`
+	Points      float64  `json:"points" yaml:"points"`
+	MaxSteps    int      `json:"max_steps" yaml:"max_steps"`
+	Timeout     string   `json:"timeout" yaml:"timeout"`
+}
+
+// Mismatch the first result that is different, an empty side means the result is missing
+type Mismatch struct {
+	Index    int    `json:"index"`
+	Expected string `json:"expected"`
+	Received string `json:"received"`
+}
+
+// CaseReport the score of a submission in one case
+type CaseReport struct {
+	Name     string    `json:"name"`
+	Points   float64   `json:"points"`
+	Score    float64   `json:"score"`
+	Passed   bool      `json:"passed"`
+	Steps    int       `json:"steps"`
+	Cycles   int64     `json:"cycles"`
+	Elapsed  float64   `json:"elapsed_ms"`
+	Mismatch *Mismatch `json:"mismatch,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// SubmissionReport the score of a submission in every case, the cycles are the sum of the cases
+// and the size is the number of instructions
+type SubmissionReport struct {
+	File   string       `json:"file"`
+	Score  float64      `json:"score"`
+	Total  float64      `json:"total"`
+	Cycles int64        `json:"cycles"`
+	Size   int          `json:"size"`
+	Error  string       `json:"error,omitempty"`
+	Cases  []CaseReport `json:"cases"`
+}
+
+// GradeReport the report of `fasm grade -json`
+type GradeReport struct {
+	Rubric      string             `json:"rubric"`
+	Submissions []SubmissionReport `json:"submissions"`
+}
+
+// loadRubric read a rubric in JSON or, for the `.yaml` and `.yml` files, in YAML, the costs that
+// aren't in the rubric are the default ones
+func loadRubric(path string) (*Rubric, error) {
+	rubric := Rubric{Costs: DEFAULT_COSTS}
+	if err := decodeConfig(path, &rubric); err != nil {
+		return nil, err
+	}
+
+	if len(rubric.Cases) == 0 {
+		return nil, fmt.Errorf("%s: %s", path, i18n.Sprintf(I18N_GRADE_ERR_NO_CASES))
+	}
+	if _, err := rubricTimeout(rubric.Timeout, GRADE_TIMEOUT); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for i, c := range rubric.Cases {
+		if c.Name == "" {
+			rubric.Cases[i].Name = fmt.Sprintf("%d", i+1)
+		}
+		if _, err := rubricTimeout(c.Timeout, GRADE_TIMEOUT); err != nil {
+			return nil, fmt.Errorf("%s: %s: %v", path, rubric.Cases[i].Name, err)
+		}
+	}
+	return &rubric, nil
+}
+
+// rubricTimeout parse a duration like `2s`, empty is the default
+func rubricTimeout(text string, def time.Duration) (time.Duration, error) {
+	if text == "" {
+		return def, nil
+	}
+	return time.ParseDuration(text)
+}
+
+// limits the step limit and the timeout of the case, falling back to the ones of the rubric
+func (r Rubric) limits(c RubricCase) (int, time.Duration) {
+	maxSteps := c.MaxSteps
+	if maxSteps == 0 {
+		maxSteps = r.MaxSteps
+	}
+	if maxSteps == 0 {
+		maxSteps = GOLDEN_MAX_STEPS
+	}
+	def, _ := rubricTimeout(r.Timeout, GRADE_TIMEOUT)
+	timeout, _ := rubricTimeout(c.Timeout, def)
+	return maxSteps, timeout
+}
+
+func timeoutError(line int, timeout time.Duration) error {
+	return executionError(line, formatError("[limit]", I18N_EXEC_ERR_TIMEOUT, timeout))
+}
+
+// executeGraded execute the program with its own VM, stopping at the step limit or at the timeout
+func executeGraded(prog Program, input []int64, maxSteps int, timeout time.Duration, costs CostTable) (results []WriteResult, stats Stats, err error) {
+	vm := newVM(prog, input)
+	vm.maxSteps = maxSteps
+	vm.costs = &costs
+	defer func() {
+		stats = vm.stats()
+		// a bug in the VM fails only the case, not the whole grading
+		if r := recover(); r != nil {
+			results, err = vm.results, fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	deadline := time.Now().Add(timeout)
+	for !vm.done() {
+		if vm.steps%GRADE_CLOCK_STEPS == 0 && time.Now().After(deadline) {
+			return vm.results, stats, timeoutError(prog.instructions[vm.pc].line, timeout)
+		}
+		if err := vm.step(); err != nil {
+			return vm.results, stats, err
+		}
+	}
+	return vm.results, stats, nil
+}
+
+// firstMismatch the first line that is different between the expected and the received outputs
+func firstMismatch(expected []string, received []string) *Mismatch {
+	for i := 0; i < len(expected) || i < len(received); i++ {
+		m := Mismatch{Index: i + 1}
+		if i < len(expected) {
+			m.Expected = expected[i]
+		}
+		if i < len(received) {
+			m.Received = received[i]
+		}
+		if m.Expected != m.Received {
+			return &m
+		}
+	}
+	return nil
+}
+
+// gradeCase execute the case, only a case that passes receives its points
+func gradeCase(rubric Rubric, c RubricCase, prog Program) CaseReport {
+	report := CaseReport{Name: c.Name, Points: c.Points}
+	maxSteps, timeout := rubric.limits(c)
+
+	start := time.Now()
+	results, stats, err := executeGraded(prog, c.Input, maxSteps, timeout, rubric.Costs)
+	report.Elapsed = float64(time.Since(start).Microseconds()) / 1000
+	report.Steps = stats.Steps
+	report.Cycles = stats.Cycles
+
+	var received []string
+	for _, r := range results {
+		received = append(received, r.ToString())
+	}
+	expected := c.Expect
+	if c.ExpectError != "" && (err == nil || !matchError(c.ExpectError, err)) {
+		expected = append(append([]string{}, expected...), INLINE_EXPECT_ERROR+" "+c.ExpectError)
+	}
+	if err != nil && (c.ExpectError == "" || !matchError(c.ExpectError, err)) {
+		report.Error = err.Error()
+		received = append(received, err.Error())
+	}
+
+	report.Mismatch = firstMismatch(expected, received)
+	report.Passed = report.Mismatch == nil
+	if report.Passed {
+		report.Score = c.Points
+	}
+	return report
+}
+
+// gradeSubmission compile the submission and run it against every case of the rubric
+func gradeSubmission(rubric Rubric, file string) SubmissionReport {
+	report := SubmissionReport{File: file, Cases: []CaseReport{}}
+	for _, c := range rubric.Cases {
+		report.Total += c.Points
+	}
+
+	prog, err := loadProgram(file)
+	if err != nil {
+		report.Error = err.Error()
+		for _, c := range rubric.Cases {
+			report.Cases = append(report.Cases, CaseReport{Name: c.Name, Points: c.Points, Error: err.Error()})
+		}
+		return report
+	}
+	report.Size = len(prog.instructions)
+	for _, c := range rubric.Cases {
+		cr := gradeCase(rubric, c, *prog)
+		report.Score += cr.Score
+		report.Cycles += cr.Cycles
+		report.Cases = append(report.Cases, cr)
+	}
+	return report
+}
+
+// grade the submissions with `jobs` at the same time, the reports keep the order of the files
+func grade(rubric Rubric, files []string, jobs int) []SubmissionReport {
+	if jobs < 1 {
+		jobs = 1
+	}
+	reports := make([]SubmissionReport, len(files))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				reports[i] = gradeSubmission(rubric, files[i])
+			}
+		}()
+	}
+	for i := range files {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return reports
+}
+
+// mismatchText the feedback of a case that failed
+func mismatchText(m *Mismatch) string {
+	switch {
+	case m.Expected == "":
+		return i18n.Sprintf(I18N_GRADE_EXTRA, m.Index, m.Received)
+	case m.Received == "":
+		return i18n.Sprintf(I18N_GRADE_MISSING, m.Index, m.Expected)
+	}
+	return i18n.Sprintf(I18N_GRADE_MISMATCH, m.Index, m.Expected, m.Received)
+}
+
+// printGradeReport the human readable report
+func printGradeReport(reports []SubmissionReport) {
+	for _, s := range reports {
+		if s.Error != "" {
+			i18n.Printf(I18N_GRADE_SUBMISSION, s.File, s.Score, s.Total)
+			fmt.Printf("  %s\n", s.Error)
+			continue
+		}
+		i18n.Printf(I18N_GRADE_SUBMISSION_STATS, s.File, s.Score, s.Total, s.Cycles, s.Size)
+		for _, c := range s.Cases {
+			if c.Passed {
+				i18n.Printf(I18N_GRADE_CASE_PASS, c.Name, c.Score, c.Points, c.Cycles)
+				continue
+			}
+			i18n.Printf(I18N_GRADE_CASE_FAIL, c.Name, c.Score, c.Points, c.Cycles)
+			fmt.Printf("       %s\n", mismatchText(c.Mismatch))
+		}
+	}
+}
+
+// leaderboard the submissions from the best, the ties in the score are broken by the fewest cycles and then by the smallest size
+func leaderboard(reports []SubmissionReport) []SubmissionReport {
+	ranked := append([]SubmissionReport{}, reports...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Cycles != b.Cycles {
+			return a.Cycles < b.Cycles
+		}
+		return a.Size < b.Size
+	})
+	return ranked
+}
+
+func printLeaderboard(reports []SubmissionReport) {
+	i18n.Printf(I18N_GRADE_LEADERBOARD)
+	for i, s := range leaderboard(reports) {
+		i18n.Printf(I18N_GRADE_RANK, i+1, s.Score, s.Cycles, s.Size, s.File)
+	}
+}
+
+// runGrade the `grade` command, score the submissions with a rubric
+func runGrade(args []string) int {
+	flags := flag.NewFlagSet("grade", flag.ExitOnError)
+	jobs := flags.Int("j", runtime.NumCPU(), i18n.Sprintf(I18N_TEST_FLAG_JOBS))
+	report := flags.String("json", "", i18n.Sprintf(I18N_GRADE_FLAG_JSON))
+	rank := flags.Bool("leaderboard", false, i18n.Sprintf(I18N_GRADE_FLAG_LEADERBOARD))
+	flags.Parse(args)
+
+	if flags.NArg() < 2 {
+		printUsage()
+		return 1
+	}
+	rubric, err := loadRubric(flags.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	files := flags.Args()[1:]
+	for _, file := range files {
+		if !isProgramFile(file) {
+			i18n.Println(I18N_ERR_PROG_NEED_ASM_EXT)
+			return 1
+		}
+	}
+
+	// with `-json -` the JSON report replaces the human readable one
+	reports := grade(*rubric, files, *jobs)
+	if *report != "-" {
+		printGradeReport(reports)
+		if *rank {
+			printLeaderboard(reports)
+		}
+	}
+
+	if *report != "" {
+		dat, err := json.MarshalIndent(GradeReport{Rubric: rubric.Name, Submissions: reports}, "", "  ")
+		if err == nil && *report == "-" {
+			fmt.Println(string(dat))
+		} else if err == nil {
+			err = os.WriteFile(*report, append(dat, '\n'), 0644)
+		}
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const RUBRIC_YAML = `name: double
+max_steps: 1000
+cases:
+  - name: small
+    input: [21]
+    expect: ["$ [ 1 ] 42"]
+    points: 2
+  - name: negative
+    input: [-3, 5]
+    expect: ["$ [ 1 ] -6", "$ [ 1 ] 10"]
+    points: 3
+  - name: empty
+    expect_error: no more input
+    points: 1
+    max_steps: 10
+`
+
+const RUBRIC_JSON = `{"name": "double", "max_steps": 1000, "cases": [
+	{"name": "small", "input": [21], "expect": ["$ [ 1 ] 42"], "points": 2},
+	{"name": "negative", "input": [-3, 5], "expect": ["$ [ 1 ] -6", "$ [ 1 ] 10"], "points": 3},
+	{"name": "empty", "expect_error": "no more input", "points": 1, "max_steps": 10}
+]}`
+
+func TestGrade(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"rubric.yaml": RUBRIC_YAML,
+		"rubric.json": RUBRIC_JSON,
+		"right.asm":   "read $0\nloop:\n$1 = $0 * 2\nwrite $1\nread $0 end\nto loop\nend:\n",
+		"once.asm":    "read $0\n$1 = $0 + $0\nwrite $1\n",
+		"forever.asm": "loop:\nto loop\n",
+		"broken.asm":  "write\n",
+	}
+	for name, code := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(code), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	yamlRubric, err := loadRubric(filepath.Join(dir, "rubric.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonRubric, err := loadRubric(filepath.Join(dir, "rubric.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(yamlRubric, jsonRubric) {
+		t.Fatalf("\nExpected: %+v\nReceived: %+v", jsonRubric, yamlRubric)
+	}
+
+	submissions := []string{"right.asm", "once.asm", "forever.asm", "broken.asm"}
+	for i := range submissions {
+		submissions[i] = filepath.Join(dir, submissions[i])
+	}
+	reports := grade(*yamlRubric, submissions, 2)
+
+	scores := []float64{6, 3, 0, 0}
+	for i, r := range reports {
+		if r.Score != scores[i] {
+			t.Errorf("%s\nExpected: '%v'\nReceived: '%v'", r.File, scores[i], r.Score)
+		}
+		if r.Total != 6 {
+			t.Errorf("%s\nExpected: '%v'\nReceived: '%v'", r.File, 6, r.Total)
+		}
+		if len(r.Cases) != 3 {
+			t.Errorf("%s\nExpected: '%v'\nReceived: '%v'", r.File, 3, len(r.Cases))
+		}
+	}
+
+	once := reports[1].Cases[1]
+	expected := Mismatch{Index: 2, Expected: "$ [ 1 ] 10", Received: ""}
+	if once.Mismatch == nil || *once.Mismatch != expected {
+		t.Errorf("\nExpected: %+v\nReceived: %+v", expected, once.Mismatch)
+	}
+
+	forever := reports[2].Cases[2]
+	if forever.Steps != 10 {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", 10, forever.Steps)
+	}
+	if forever.Mismatch == nil || forever.Mismatch.Expected != "expect-error: no more input" {
+		t.Errorf("\nExpected: '%v'\nReceived: %+v", "expect-error: no more input", forever.Mismatch)
+	}
+	if reports[3].Error == "" {
+		t.Errorf("%s\nExpected: an error\nReceived: '%v'", reports[3].File, reports[3].Error)
+	}
+
+	if _, err := loadRubric(filepath.Join(dir, "right.asm")); err == nil {
+		t.Errorf("\nExpected: an error\nReceived: '%v'", err)
+	}
+}
+
+func TestGradeTimeout(t *testing.T) {
+	prog, err := compile("loop:\nto loop\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, stats, err := executeGraded(*prog, nil, 0, 0, DEFAULT_COSTS)
+	if err == nil || !strings.Contains(err.Error(), I18N_EXEC_ERR_TIMEOUT) {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", I18N_EXEC_ERR_TIMEOUT, err)
+	}
+	if stats.Steps != 0 {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", 0, stats.Steps)
+	}
+}
@@ -0,0 +1,476 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The server speaks LSP over stdio with the full text of the document sent on every change.
+// The columns count bytes, which is the same of the UTF-16 units of the protocol for ASCII sources.
+
+const (
+	LSP_ERR_PARSE            = -32700
+	LSP_ERR_METHOD_NOT_FOUND = -32601
+	LSP_ERR_INVALID_REQUEST  = -32600
+
+	LSP_SEVERITY_ERROR   = 1
+	LSP_SEVERITY_WARNING = 2
+
+	LSP_COMPLETION_FUNCTION  = 3
+	LSP_COMPLETION_REFERENCE = 18
+)
+
+type lspMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type lspResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result"`
+}
+
+type lspErrorResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Error   lspError        `json:"error"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lspNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspTextDocumentPosition struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Position lspPosition `json:"position"`
+	Context  struct {
+		IncludeDeclaration bool `json:"includeDeclaration"`
+	} `json:"context"`
+}
+
+type lspDidChange struct {
+	TextDocument struct {
+		URI  string `json:"uri"`
+		Text string `json:"text"`
+	} `json:"textDocument"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+}
+
+type lspHover struct {
+	Contents struct {
+		Kind  string `json:"kind"`
+		Value string `json:"value"`
+	} `json:"contents"`
+	Range lspRange `json:"range"`
+}
+
+type lspCompletionItem struct {
+	Label  string `json:"label"`
+	Kind   int    `json:"kind"`
+	Detail string `json:"detail"`
+}
+
+// LabelSymbol where a label is defined or used in the source
+type LabelSymbol struct {
+	name string
+	rng  lspRange
+	def  bool
+}
+
+// labelSymbols find the labels with the same rules of `compile`, even when the source has errors
+func labelSymbols(code string) []LabelSymbol {
+	lines := strings.Split(code, "\n")
+	kw, err := getKeywords(lines)
+	if err != nil {
+		kw = KEYWORDS[LANG_EN]
+	}
+
+	var symbols []LabelSymbol
+	for iline, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		spans := tokenSpans(line)
+		tokens := tokenize(line)
+		if isCommentInst(tokens) {
+			continue
+		}
+		symbol := func(i int, end int, def bool) {
+			rng := lspRange{lspPosition{iline, spans[i][0]}, lspPosition{iline, end}}
+			symbols = append(symbols, LabelSymbol{name: line[spans[i][0]:end], rng: rng, def: def})
+		}
+		if _, exists := hasLabel(tokens); exists {
+			symbol(0, spans[0][1]-1, true)
+		} else if tokens[0] == kw.to && len(tokens) > 1 && isWord(tokens[1]) {
+			symbol(1, spans[1][1], false)
+		} else if tokens[0] == kw.read && len(tokens) > 2 && isWord(tokens[2]) {
+			symbol(2, spans[2][1], false)
+		}
+	}
+	return symbols
+}
+
+// symbolAt the label under the position
+func symbolAt(symbols []LabelSymbol, pos lspPosition) (LabelSymbol, bool) {
+	for _, s := range symbols {
+		if s.rng.Start.Line == pos.Line && s.rng.Start.Character <= pos.Character && pos.Character <= s.rng.End.Character {
+			return s, true
+		}
+	}
+	return LabelSymbol{}, false
+}
+
+// tokenAt the index of the token under the position, or of the token being typed after the last one
+func tokenAt(line string, character int) (int, [][2]int) {
+	spans := tokenSpans(line)
+	i := 0
+	for i < len(spans) && spans[i][1] < character {
+		i++
+	}
+	return i, spans
+}
+
+// documentLine the line of the document, empty when it doesn't exist
+func documentLine(code string, line int) string {
+	lines := strings.Split(code, "\n")
+	if line < 0 || line >= len(lines) {
+		return ""
+	}
+	return strings.TrimSuffix(lines[line], "\r")
+}
+
+// diagnostics the compilation error or the warnings of `check` when the program compiles
+func diagnostics(code string) []lspDiagnostic {
+	lineRange := func(line int) lspRange {
+		return lspRange{lspPosition{line, 0}, lspPosition{line, len(documentLine(code, line))}}
+	}
+
+	diags := []lspDiagnostic{}
+	prog, err := compile(code)
+	if err != nil {
+		var lerr *LineError
+		if errors.As(err, &lerr) {
+			diags = append(diags, lspDiagnostic{lineRange(lerr.line - 1), LSP_SEVERITY_ERROR, "fasm", lerr.err.Error()})
+		} else {
+			diags = append(diags, lspDiagnostic{lineRange(0), LSP_SEVERITY_ERROR, "fasm", err.Error()})
+		}
+		return diags
+	}
+	for _, w := range checkUninitialized(*prog) {
+		msg := i18n.Sprintf(I18N_CHECK_WARN_UNINITIALIZED, w.slot)
+		diags = append(diags, lspDiagnostic{lineRange(w.line - 1), LSP_SEVERITY_WARNING, "fasm", msg})
+	}
+	return diags
+}
+
+// hoverText explain the value, keyword or label under the position
+func hoverText(code string, pos lspPosition) (string, lspRange, bool) {
+	line := documentLine(code, pos.Line)
+	i, spans := tokenAt(line, pos.Character)
+	if i >= len(spans) || pos.Character < spans[i][0] {
+		return "", lspRange{}, false
+	}
+	token := line[spans[i][0]:spans[i][1]]
+	rng := lspRange{lspPosition{pos.Line, spans[i][0]}, lspPosition{pos.Line, spans[i][1]}}
+
+	if s, ok := symbolAt(labelSymbols(code), pos); ok {
+		if prog, err := compile(code); err == nil {
+			if index, exists := prog.labels[s.name]; exists {
+				return i18n.Sprintf(I18N_LSP_HOVER_LABEL, s.name, index), s.rng, true
+			}
+		}
+		return "", lspRange{}, false
+	}
+	if v := hasValue(token); v != nil {
+		switch v.typ {
+		case VAL_VAR:
+			return i18n.Sprintf(I18N_LSP_HOVER_VAR, v.val, v.val), rng, true
+		case VAL_REF:
+			return i18n.Sprintf(I18N_LSP_HOVER_REF, v.val, v.val), rng, true
+		}
+		return "", lspRange{}, false
+	}
+
+	kw, err := getKeywords(strings.Split(code, "\n"))
+	if err != nil {
+		return "", lspRange{}, false
+	}
+	docs := map[string]string{
+		kw.to:     I18N_LSP_HOVER_TO,
+		kw.iff:    I18N_LSP_HOVER_IF,
+		kw.write:  I18N_LSP_HOVER_WRITE,
+		kw.read:   I18N_LSP_HOVER_READ,
+		kw.assert: I18N_LSP_HOVER_ASSERT,
+		kw.sys:    I18N_LSP_HOVER_SYS,
+	}
+	if doc, exists := docs[token]; exists {
+		return i18n.Sprintf(doc), rng, true
+	}
+	return "", lspRange{}, false
+}
+
+// completions the labels of the document when the position is the label of a `to` or of a `read`,
+// the registered syscalls when it is the name of a `sys`
+func completions(code string, pos lspPosition) []lspCompletionItem {
+	items := []lspCompletionItem{}
+	kw, err := getKeywords(strings.Split(code, "\n"))
+	if err != nil {
+		return items
+	}
+	line := documentLine(code, pos.Line)
+	i, spans := tokenAt(line, pos.Character)
+	if len(spans) == 0 {
+		return items
+	}
+	first := line[spans[0][0]:spans[0][1]]
+	if first == kw.sys && i == 1 {
+		names := make([]string, 0, len(SYSCALLS))
+		for name := range SYSCALLS {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			items = append(items, lspCompletionItem{Label: name, Kind: LSP_COMPLETION_FUNCTION, Detail: i18n.Sprintf(I18N_LSP_COMPLETION_SYS)})
+		}
+		return items
+	}
+	if !(first == kw.to && i == 1) && !(first == kw.read && i == 2) {
+		return items
+	}
+
+	for _, s := range labelSymbols(code) {
+		if s.def {
+			items = append(items, lspCompletionItem{Label: s.name, Kind: LSP_COMPLETION_REFERENCE, Detail: i18n.Sprintf(I18N_LSP_COMPLETION_LABEL)})
+		}
+	}
+	return items
+}
+
+// LSPServer the state of a `fasm lsp` session
+type LSPServer struct {
+	in       *bufio.Reader
+	out      io.Writer
+	docs     map[string]string
+	shutdown bool
+}
+
+func newLSPServer(in io.Reader, out io.Writer) *LSPServer {
+	return &LSPServer{in: bufio.NewReader(in), out: out, docs: make(map[string]string)}
+}
+
+// readMessage read one message with the `Content-Length` header
+func (s *LSPServer) readMessage() ([]byte, error) {
+	length := -1
+	for {
+		header, err := s.in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		header = strings.TrimSpace(header)
+		if header == "" {
+			break
+		}
+		if v := strings.TrimPrefix(header, "Content-Length:"); v != header {
+			length, err = strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("missing Content-Length")
+	}
+	body := make([]byte, length)
+	_, err := io.ReadFull(s.in, body)
+	return body, err
+}
+
+func (s *LSPServer) write(msg interface{}) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func (s *LSPServer) reply(id json.RawMessage, result interface{}) {
+	s.write(lspResponse{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+func (s *LSPServer) replyError(id json.RawMessage, code int, message string) {
+	s.write(lspErrorResponse{JSONRPC: "2.0", ID: id, Error: lspError{Code: code, Message: message}})
+}
+
+func (s *LSPServer) publishDiagnostics(uri string) {
+	params := struct {
+		URI         string          `json:"uri"`
+		Diagnostics []lspDiagnostic `json:"diagnostics"`
+	}{uri, diagnostics(s.docs[uri])}
+	s.write(lspNotification{JSONRPC: "2.0", Method: "textDocument/publishDiagnostics", Params: params})
+}
+
+// locations where the label under the position is defined or used
+func (s *LSPServer) locations(p lspTextDocumentPosition, def bool, refs bool) []lspLocation {
+	uri := p.TextDocument.URI
+	symbols := labelSymbols(s.docs[uri])
+	locs := []lspLocation{}
+	at, ok := symbolAt(symbols, p.Position)
+	if !ok {
+		return locs
+	}
+	for _, sym := range symbols {
+		if sym.name == at.name && (sym.def && def || !sym.def && refs) {
+			locs = append(locs, lspLocation{uri, sym.rng})
+		}
+	}
+	return locs
+}
+
+// handle answer one message, the result is false when the session ended
+func (s *LSPServer) handle(msg lspMessage) bool {
+	var doc lspDidChange
+	var pos lspTextDocumentPosition
+	switch msg.Method {
+	case "textDocument/didOpen", "textDocument/didChange", "textDocument/didClose":
+		if err := json.Unmarshal(msg.Params, &doc); err != nil {
+			return true
+		}
+	case "textDocument/definition", "textDocument/references", "textDocument/hover", "textDocument/completion":
+		if err := json.Unmarshal(msg.Params, &pos); err != nil {
+			s.replyError(msg.ID, LSP_ERR_INVALID_REQUEST, err.Error())
+			return true
+		}
+	}
+
+	switch msg.Method {
+	case "initialize":
+		s.reply(msg.ID, map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":   1,
+				"definitionProvider": true,
+				"referencesProvider": true,
+				"hoverProvider":      true,
+				"completionProvider": map[string]interface{}{"triggerCharacters": []string{" "}},
+			},
+			"serverInfo": map[string]string{"name": "fasm"},
+		})
+		break
+	case "shutdown":
+		s.shutdown = true
+		s.reply(msg.ID, nil)
+		break
+	case "exit":
+		return false
+	case "textDocument/didOpen":
+		s.docs[doc.TextDocument.URI] = doc.TextDocument.Text
+		s.publishDiagnostics(doc.TextDocument.URI)
+		break
+	case "textDocument/didChange":
+		if len(doc.ContentChanges) > 0 {
+			s.docs[doc.TextDocument.URI] = doc.ContentChanges[len(doc.ContentChanges)-1].Text
+		}
+		s.publishDiagnostics(doc.TextDocument.URI)
+		break
+	case "textDocument/didClose":
+		delete(s.docs, doc.TextDocument.URI)
+		break
+	case "textDocument/definition":
+		s.reply(msg.ID, s.locations(pos, true, false))
+		break
+	case "textDocument/references":
+		s.reply(msg.ID, s.locations(pos, pos.Context.IncludeDeclaration, true))
+		break
+	case "textDocument/hover":
+		text, rng, ok := hoverText(s.docs[pos.TextDocument.URI], pos.Position)
+		if !ok {
+			s.reply(msg.ID, nil)
+			break
+		}
+		var hover lspHover
+		hover.Contents.Kind = "plaintext"
+		hover.Contents.Value = text
+		hover.Range = rng
+		s.reply(msg.ID, hover)
+		break
+	case "textDocument/completion":
+		s.reply(msg.ID, completions(s.docs[pos.TextDocument.URI], pos.Position))
+		break
+	default:
+		// the notifications that aren't supported are ignored, like `initialized`
+		if msg.ID != nil {
+			s.replyError(msg.ID, LSP_ERR_METHOD_NOT_FOUND, msg.Method)
+		}
+	}
+	return true
+}
+
+// serve answer the messages until `exit`, the result is the exit code of the process
+func (s *LSPServer) serve() int {
+	for {
+		body, err := s.readMessage()
+		if err != nil {
+			return 1
+		}
+		var msg lspMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			s.replyError(json.RawMessage("null"), LSP_ERR_PARSE, err.Error())
+			continue
+		}
+		if !s.handle(msg) {
+			if s.shutdown {
+				return 0
+			}
+			return 1
+		}
+	}
+}
+
+// runLSP the `lsp` command, a language server over stdio
+func runLSP(args []string) int {
+	flags := flag.NewFlagSet("lsp", flag.ExitOnError)
+	flags.Parse(args)
+
+	return newLSPServer(os.Stdin, os.Stdout).serve()
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// lspClient a scripted client, every message is sent before the server starts
+type lspClient struct {
+	in     bytes.Buffer
+	nextID int
+}
+
+func (c *lspClient) send(method string, params interface{}, request bool) {
+	msg := map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params}
+	if request {
+		c.nextID++
+		msg["id"] = c.nextID
+	}
+	body, _ := json.Marshal(msg)
+	fmt.Fprintf(&c.in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func (c *lspClient) position(uri string, line int, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]string{"uri": uri},
+		"position":     map[string]int{"line": line, "character": character},
+		"context":      map[string]bool{"includeDeclaration": true},
+	}
+}
+
+// run the server with the script, the messages it wrote are decoded in order
+func (c *lspClient) run(t *testing.T) (int, []map[string]interface{}) {
+	var out bytes.Buffer
+	code := newLSPServer(&c.in, &out).serve()
+
+	var msgs []map[string]interface{}
+	r := newLSPServer(bufio.NewReader(&out), nil)
+	for {
+		body, err := r.readMessage()
+		if err != nil {
+			break
+		}
+		var msg map[string]interface{}
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return code, msgs
+}
+
+func encode(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func TestLSP(t *testing.T) {
+	uri := "file:///loop.asm"
+	code := "main:\n$0 = 3\nloop:\n$0 = $0 - 1\nwrite &0\nto loop if $0 > 0\nread $1 main\nto \n"
+
+	var c lspClient
+	c.send("initialize", map[string]interface{}{}, true)
+	c.send("initialized", map[string]interface{}{}, false)
+	c.send("textDocument/didOpen", map[string]interface{}{"textDocument": map[string]string{"uri": uri, "text": code}}, false)
+	c.send("textDocument/definition", c.position(uri, 5, 5), true)
+	c.send("textDocument/references", c.position(uri, 0, 1), true)
+	c.send("textDocument/hover", c.position(uri, 4, 7), true)
+	c.send("textDocument/completion", c.position(uri, 7, 3), true)
+	c.send("textDocument/completion", c.position(uri, 4, 3), true)
+	c.send("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]string{"uri": uri},
+		"contentChanges": []map[string]string{{"text": strings.Replace(code, "to \n", "write $2\n", 1)}},
+	}, false)
+	c.send("textDocument/hover", c.position(uri, 2, 2), true)
+	c.send("shutdown", nil, true)
+	c.send("exit", nil, false)
+
+	status, msgs := c.run(t)
+	if status != 0 {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", 0, status)
+	}
+	if len(msgs) != 10 {
+		t.Fatalf("\nExpected: '%v'\nReceived: '%v'", 10, len(msgs))
+	}
+
+	loc := func(line, start, end int) string {
+		return fmt.Sprintf(`{"range":{"end":{"character":%d,"line":%d},"start":{"character":%d,"line":%d}},"uri":"%s"}`, end, line, start, line, uri)
+	}
+	expected := []struct {
+		index int
+		field string
+		value string
+	}{
+		{2, "result", "[" + loc(2, 0, 4) + "]"},
+		{3, "result", "[" + loc(0, 0, 4) + "," + loc(6, 8, 12) + "]"},
+		{6, "result", "[]"},
+	}
+	for _, e := range expected {
+		if received := encode(msgs[e.index][e.field]); received != e.value {
+			t.Errorf("message %d\nExpected: '%v'\nReceived: '%v'", e.index, e.value, received)
+		}
+	}
+
+	if caps := encode(msgs[0]["result"]); !strings.Contains(caps, `"definitionProvider":true`) {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", `"definitionProvider":true`, caps)
+	}
+
+	diags := msgs[1]["params"].(map[string]interface{})["diagnostics"].([]interface{})
+	if len(diags) != 1 {
+		t.Fatalf("\nExpected: '%v'\nReceived: '%v'", 1, len(diags))
+	}
+	errRange := `{"end":{"character":3,"line":7},"start":{"character":0,"line":7}}`
+	if received := encode(diags[0].(map[string]interface{})["range"]); received != errRange {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", errRange, received)
+	}
+
+	hover := msgs[4]["result"].(map[string]interface{})["contents"].(map[string]interface{})["value"].(string)
+	if !strings.HasPrefix(hover, "&0") {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", "&0", hover)
+	}
+	if hover := encode(msgs[8]["result"]); !strings.Contains(hover, "loop") || !strings.Contains(hover, "1") {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", "loop", hover)
+	}
+
+	labels := encode(msgs[5]["result"])
+	for _, label := range []string{`"label":"main"`, `"label":"loop"`} {
+		if !strings.Contains(labels, label) {
+			t.Errorf("\nExpected: '%v'\nReceived: '%v'", label, labels)
+		}
+	}
+
+	// after the change only the warning of $2 is left
+	diags = msgs[7]["params"].(map[string]interface{})["diagnostics"].([]interface{})
+	if len(diags) != 1 {
+		t.Fatalf("\nExpected: '%v'\nReceived: '%v'", 1, len(diags))
+	}
+	if severity := diags[0].(map[string]interface{})["severity"].(float64); severity != LSP_SEVERITY_WARNING {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", LSP_SEVERITY_WARNING, severity)
+	}
+}
+
+func TestLSPExitWithoutShutdown(t *testing.T) {
+	var c lspClient
+	c.send("textDocument/unknown", nil, true)
+	c.send("exit", nil, false)
+
+	status, msgs := c.run(t)
+	if status != 1 {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", 1, status)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("\nExpected: '%v'\nReceived: '%v'", 1, len(msgs))
+	}
+	if code := encode(msgs[0]["error"].(map[string]interface{})["code"]); code != fmt.Sprint(LSP_ERR_METHOD_NOT_FOUND) {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", LSP_ERR_METHOD_NOT_FOUND, code)
+	}
+}
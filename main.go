@@ -1,6 +1,8 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"strconv"
@@ -14,7 +16,9 @@ var i18n *message.Printer
 
 const (
 	I18N_ERR_PROG_TOO_MANY_PARMS = "too many parameters"
-	I18N_ERR_PROG_NEED_ASM_EXT   = "the file must have an .asm extension"
+	I18N_ERR_PROG_NEED_ASM_EXT   = "the file must have an .asm, .lst or .fbc extension"
+
+	I18N_ERR_MISSING_PARAM = "missing a parameter after"
 
 	I18N_ERR_OP_ONLY_ONE_LEFT_VAL = "must have only one operation left value, but received"
 	I18N_ERR_OP_LEFT_VAL_INVALID  = "invalid operation left value"
@@ -34,21 +38,163 @@ const (
 	I18N_ERR_WRITE_EXPECT_VALUE   = "expecting a value, but received"
 	I18N_ERR_WRITE_ONLY_ONE_PARAM = "expecting only one value as a parameter, but received"
 
-	I18N_ERR_READ_EXPECT_VALUE  = "expecting a value, but received"
-	I18N_ERR_READ_INVALID_WORD  = "expecting valid word, but received"
-	I18N_ERR_READ_NOTHING       = "trying to read when there is no more input"
-	I18N_ERR_READ_NO_ELSE_LABEL = "no else label"
+	I18N_ERR_READ_EXPECT_VALUE   = "expecting a value, but received"
+	I18N_ERR_READ_INVALID_WORD   = "expecting valid word, but received"
+	I18N_ERR_READ_TARGET_INVALID = "invalid read target"
+	I18N_ERR_READ_NOTHING        = "trying to read when there is no more input"
+	I18N_ERR_READ_NO_ELSE_LABEL  = "no else label"
+
+	I18N_ERR_ASSERT_EXPECT_MESSAGE = "expecting a message between quotes after the condition, but received"
+
+	I18N_ERR_SYS_INVALID_WORD = "expecting the name of a syscall, but received"
+	I18N_ERR_SYS_NOT_FOUND    = "syscall not found"
+	I18N_ERR_SYS_ARITY        = "wrong number of arguments, expecting"
+	I18N_ERR_SYS_NO_TRANSPILE = "a syscall can't be transpiled"
 
 	I18N_COMPILE_ERR_TEMPLATE = "[Compilation error: line %d] %v."
 
 	I18N_COMPILE_ERR_INST_NOT_FOUND  = "instruction not found"
 	I18N_COMPILE_ERR_LABEL_NOT_FOUND = "label not defined"
+	I18N_COMPILE_ERR_LANG_NOT_FOUND  = "language not supported"
 
 	I18N_EXEC_ERR_INVALID_MEMORY_ACCESS = "invalid memory access"
+	I18N_EXEC_ERR_ASSERT                = "assertion failed"
+	I18N_EXEC_ERR_DIVISION_BY_ZERO      = "division by zero"
+	I18N_EXEC_ERR_STEP_LIMIT            = "too many instructions executed, the limit is"
+	I18N_EXEC_ERR_OUTPUT_LIMIT          = "too many results, the limit is"
+	I18N_EXEC_ERR_TIMEOUT               = "the program ran for longer than"
+	I18N_EXEC_ERR_SYS_SET               = "the syscall can't write to a constant argument"
+	I18N_EXEC_ERR_SYS_RANGE             = "the maximum is smaller than the minimum or the range is too big"
 
 	I18N_EXEC_ERR_TEMPLATE = "[Execution error: line %d] %v."
 
 	I18N_INPUT_ERR_TEMPLATE = "On file '%s' line %d was not possible to convert '%s' into a number\n"
+
+	I18N_FMT_FLAG_CHECK    = "only check if the files are formatted"
+	I18N_FMT_FLAG_WRITE    = "write the result to the file instead of printing it"
+	I18N_FMT_NOT_FORMATTED = "%s is not formatted\n"
+	I18N_FMT_ERR_CHANGED   = "formatting would change the program"
+
+	I18N_BUILD_FLAG_OUTPUT = "bytecode output file"
+
+	I18N_BYTECODE_ERR_MAGIC   = "not a bytecode file"
+	I18N_BYTECODE_ERR_VERSION = "unsupported bytecode version"
+	I18N_BYTECODE_ERR_INVALID = "invalid bytecode"
+	I18N_BYTECODE_ERR_JUMP    = "invalid jump target"
+
+	I18N_LISTING_ERR_INVALID_INDEX = "expecting the instruction index, but received"
+	I18N_LISTING_ERR_INVALID_LINE  = "expecting the index, line and instruction, but received"
+
+	I18N_CFG_FLAG_PROFILE = "execute the program with the input and show how many times each edge was taken"
+
+	I18N_FLAG_OPTIMIZE        = "optimize the program"
+	I18N_FLAG_NO_ASSERT       = "ignore the assertions"
+	I18N_FLAG_MAX_STEPS       = "stop after executing this many instructions, 0 is no limit"
+	I18N_FLAG_STATS           = "print the instructions executed, the cycles and the size of the program"
+	I18N_FLAG_COSTS           = "YAML or JSON file with the cycles of each instruction"
+	I18N_RUN_STATS            = "%d steps, %d cycles, %d instructions\n"
+	I18N_ERR_COSTS_STATS      = "-costs only works with -stats"
+	I18N_FLAG_RECORD          = "save the input read and the output to the recording file"
+	I18N_FLAG_DUMP_MEM        = "write the memory, pc and rc to a JSON file when the program stops"
+	I18N_FLAG_LOAD_MEM        = "start from the memory, pc and rc of a JSON or YAML snapshot"
+	I18N_FLAG_ENGINE          = "execution engine, switch or closure"
+	I18N_ERR_ENGINE_NOT_FOUND = "engine %s doesn't exist\n"
+	I18N_ERR_ENGINE_SWITCH    = "-stats, -record, -dump-mem and -load-mem only work with the switch engine"
+
+	I18N_TRANSPILE_FLAG_GO       = "generate a Go program"
+	I18N_TRANSPILE_FLAG_C        = "generate a C99 program"
+	I18N_TRANSPILE_FLAG_OUTPUT   = "output file"
+	I18N_TRANSPILE_ERR_NO_TARGET = "choose one language to generate"
+
+	I18N_TEST_FLAG_JOBS     = "how many programs are executed at the same time"
+	I18N_TEST_FLAG_JUNIT    = "write a JUnit XML report to the file"
+	I18N_TEST_PASS          = "ok   %s (%v)\n"
+	I18N_TEST_FAIL          = "FAIL %s (%v)\n"
+	I18N_TEST_SUMMARY       = "%d passed, %d failed in %v\n"
+	I18N_TEST_ERR_OUTPUT    = "the output is different from the expected"
+	I18N_TEST_ERR_DIRECTIVE = "invalid test directive"
+	I18N_TEST_ERR_INPUT     = "the input must be numbers, but received"
+
+	I18N_BENCH_FLAG_RUNS = "how many times the program is executed"
+	I18N_BENCH_RUNS      = "%d runs with the %s engine in %v\n"
+	I18N_BENCH_STEPS     = "%d instructions per run, %d in total\n"
+	I18N_BENCH_SPEED     = "%.0f instructions per second\n"
+	I18N_BENCH_ALLOCS    = "%d allocations and %d bytes per run\n"
+
+	I18N_CHECK_WARN_TEMPLATE      = "%s: [Warning: line %d] %v.\n"
+	I18N_CHECK_WARN_UNINITIALIZED = "$%d may be read before anything is written to it"
+
+	I18N_LSP_HOVER_VAR        = "$%d: the value stored in the memory slot %d"
+	I18N_LSP_HOVER_REF        = "&%d: the value stored in the memory slot whose address is in $%d"
+	I18N_LSP_HOVER_LABEL      = "label %s: jumps to the instruction %d"
+	I18N_LSP_HOVER_TO         = "to label [if condition]: jump to the label, when the condition is true"
+	I18N_LSP_HOVER_IF         = "if: the condition of the jump, the comparisons are joined by && and ||"
+	I18N_LSP_HOVER_WRITE      = "write value: print the value"
+	I18N_LSP_HOVER_READ       = "read target [label]: store the next input number in the target, or jump to the label when the input is over"
+	I18N_LSP_HOVER_ASSERT     = "assert condition [\"message\"]: stop the program with an error when the condition is false"
+	I18N_LSP_HOVER_SYS        = "sys name [values]: call a function of the host, like rand or time, that can write to its arguments"
+	I18N_LSP_COMPLETION_LABEL = "label"
+	I18N_LSP_COMPLETION_SYS   = "syscall"
+
+	I18N_TUI_TITLE    = "%s  %s  %.0f steps/s  step %d  pc %d"
+	I18N_TUI_PLAYING  = "playing"
+	I18N_TUI_PAUSED   = "paused"
+	I18N_TUI_FINISHED = "finished"
+	I18N_TUI_INPUT    = "input (rc %d): %s"
+	I18N_TUI_OUTPUT   = "output:"
+	I18N_TUI_HELP     = "space play/pause  s step  b back  +/- speed  r restart  [/] memory  q quit"
+
+	I18N_GRADE_FLAG_JSON        = "write a JSON report to the file, - prints it instead of the text report"
+	I18N_GRADE_ERR_NO_CASES     = "the rubric has no cases"
+	I18N_GRADE_SUBMISSION       = "%s: %g/%g\n"
+	I18N_GRADE_SUBMISSION_STATS = "%s: %g/%g, %d cycles, %d instructions\n"
+	I18N_GRADE_FLAG_LEADERBOARD = "rank the submissions by score, cycles and size"
+	I18N_GRADE_LEADERBOARD      = "rank  score  cycles  size  file\n"
+	I18N_GRADE_RANK             = "%4d  %5g  %6d  %4d  %s\n"
+	I18N_GRADE_CASE_PASS        = "  ok   %s (%g/%g, %d cycles)\n"
+	I18N_GRADE_CASE_FAIL        = "  FAIL %s (%g/%g, %d cycles)\n"
+	I18N_GRADE_MISMATCH         = "line %d: expecting '%s', but received '%s'"
+	I18N_GRADE_MISSING          = "line %d: expecting '%s', but the program stopped"
+	I18N_GRADE_EXTRA            = "line %d: nothing more was expected, but received '%s'"
+
+	I18N_RECORDING_ERR_MAGIC   = "not a recording file"
+	I18N_RECORDING_ERR_VERSION = "unsupported recording version"
+	I18N_REPLAY_FLAG_STOP      = "stop after executing this many instructions and show the state"
+	I18N_REPLAY_ERR_PROGRAM    = "the recording is of another program"
+	I18N_REPLAY_ERR_OUTPUT     = "the replay is different from the recording"
+	I18N_REPLAY_ERR_NO_SYSCALL = "the recording has no more syscall results, the arguments are"
+	I18N_REPLAY_STATE          = "step %d, pc %d, rc %d\n"
+	I18N_REPLAY_NEXT           = "next: line %d: %s\n"
+	I18N_REPLAY_OK             = "ok, %d steps and %d results like the recording\n"
+
+	I18N_COVER_FLAG_LCOV = "write the coverage to an LCOV file"
+	I18N_COVER_BRANCH    = "  [jumped %d, continued %d]"
+	I18N_COVER_RUN_ERROR = "%s: %v\n"
+	I18N_COVER_SUMMARY   = "%d runs, instructions: %.1f%% (%d/%d), branches: %.1f%% (%d/%d)\n"
+
+	I18N_SNAPSHOT_ERR_VERSION = "unsupported snapshot version"
+	I18N_SNAPSHOT_ERR_PC      = "the pc is outside of the program"
+	I18N_SNAPSHOT_ERR_RC      = "the rc is outside of the input"
+	I18N_SNAPSHOT_ERR_PROGRAM = "the snapshot is of another program"
+	I18N_SNAPSHOT_ERR_RECORD  = "a recording can't start from a snapshot"
+
+	I18N_DEBUG_AT            = "step %d, line %d: %s\n"
+	I18N_DEBUG_ENDED         = "step %d, the program ended\n"
+	I18N_DEBUG_STOPPED       = "step %d, %v\n"
+	I18N_DEBUG_BREAKPOINTS   = "breakpoints: %s\n"
+	I18N_DEBUG_CHANGED       = "$%d changed at the step %d, line %d: %s, from %d to %d\n"
+	I18N_DEBUG_NEVER_CHANGED = "$%d never changed\n"
+	I18N_DEBUG_ERR_NUMBER    = "expecting a positive number, but received '%s'\n"
+	I18N_DEBUG_ERR_SLOT      = "expecting a memory slot like $0, but received '%s'\n"
+	I18N_DEBUG_HELP          = "s [n] step  rs [n] reverse-step  c continue  rc reverse-continue  b line  d line  p [$n]  last $n  o output  q quit"
+
+	I18N_SERVE_FLAG_ADDR       = "address to listen on"
+	I18N_SERVE_FLAG_MAX_OUTPUT = "maximum results and trace steps of a run"
+	I18N_SERVE_FLAG_MAX_BODY   = "maximum size of a request in bytes"
+	I18N_SERVE_LISTENING       = "listening on http://%s\n"
+	I18N_SERVE_ERR_METHOD      = "the request must be a POST"
+	I18N_SERVE_ERR_REQUEST     = "invalid request"
+	I18N_SERVE_ERR_BUSY        = "too many programs running, try again later"
 )
 
 const MEMORY_SIZE = 1024
@@ -56,6 +202,21 @@ const MEMORY_SIZE = 1024
 const (
 	USE_HELP = iota
 	USE_RUN
+	USE_FMT
+	USE_BUILD
+	USE_DISASM
+	USE_CFG
+	USE_CHECK
+	USE_TRANSPILE
+	USE_BENCH
+	USE_TEST
+	USE_LSP
+	USE_TUI
+	USE_SERVE
+	USE_GRADE
+	USE_REPLAY
+	USE_DEBUG
+	USE_COVER
 
 	USE_INVALID_FILE
 	USE_TOO_MANY_PARAMS
@@ -70,21 +231,75 @@ func getUse() int {
 	if len(os.Args) == 1 {
 		return USE_HELP
 	}
-	if len(os.Args) != 2 {
-		return USE_TOO_MANY_PARAMS
-	}
-	if strings.ToLower(os.Args[1]) == "help" {
+	switch strings.ToLower(os.Args[1]) {
+	case "help":
 		return USE_HELP
+	case "run":
+		return USE_RUN
+	case "fmt":
+		return USE_FMT
+	case "build":
+		return USE_BUILD
+	case "disasm":
+		return USE_DISASM
+	case "cfg":
+		return USE_CFG
+	case "check":
+		return USE_CHECK
+	case "transpile":
+		return USE_TRANSPILE
+	case "bench":
+		return USE_BENCH
+	case "test":
+		return USE_TEST
+	case "lsp":
+		return USE_LSP
+	case "tui":
+		return USE_TUI
+	case "serve":
+		return USE_SERVE
+	case "grade":
+		return USE_GRADE
+	case "replay":
+		return USE_REPLAY
+	case "debug":
+		return USE_DEBUG
+	case "cover":
+		return USE_COVER
+	}
+	if len(os.Args) > 3 {
+		return USE_TOO_MANY_PARAMS
 	}
-	if !strings.HasSuffix(os.Args[1], ".asm") {
+	if !isProgramFile(os.Args[1]) {
 		return USE_INVALID_FILE
 	}
 
 	return USE_RUN
 }
 
+// isProgramFile if the file is a source or a bytecode
+func isProgramFile(filepath string) bool {
+	return strings.HasSuffix(filepath, ".asm") || strings.HasSuffix(filepath, LISTING_EXT) || strings.HasSuffix(filepath, BYTECODE_EXT)
+}
+
 func printUsage() {
-	fmt.Println("")
+	fmt.Println("usage:")
+	fmt.Println("  fasm [run] [-O] [-no-assert] [-max-steps n] [-engine switch|closure] [--stats [-costs costs.yaml]] [-record file.rec] [-dump-mem out.json] [-load-mem in.json] file.{asm,lst,fbc} [input]")
+	fmt.Println("  fasm fmt [--check] [-w] file.asm...")
+	fmt.Println("  fasm build [-O] [-o file.fbc] file.{asm,lst}")
+	fmt.Println("  fasm disasm [-O] file.{asm,lst,fbc}")
+	fmt.Println("  fasm cfg [--profile [-max-steps n]] file.{asm,lst,fbc} [input]")
+	fmt.Println("  fasm check file.{asm,lst,fbc}...")
+	fmt.Println("  fasm transpile {--go,--c} [-O] [-o output] file.{asm,lst,fbc}")
+	fmt.Println("  fasm test [-j jobs] [-max-steps n] [-junit report.xml] dir...")
+	fmt.Println("  fasm cover [-max-steps n] [-lcov coverage.info] file.{asm,lst,fbc} [input...]")
+	fmt.Println("  fasm bench [-n runs] [-O] [-no-assert] [-engine switch|closure] [-max-steps n] file.{asm,lst,fbc} [input]")
+	fmt.Println("  fasm lsp")
+	fmt.Println("  fasm tui file.{asm,lst,fbc} [input]")
+	fmt.Println("  fasm debug [-O] [-max-steps n] file.{asm,lst,fbc} [input]")
+	fmt.Println("  fasm grade [-j jobs] [-json report.json] [-leaderboard] rubric.{yaml,json} file.{asm,lst,fbc}...")
+	fmt.Println("  fasm replay [-O] [-no-assert] [-stop step] file.rec file.{asm,lst,fbc}")
+	fmt.Println("  fasm serve [-addr host:port] [-j jobs] [-max-steps n] [-max-output n] [-max-body bytes]")
 }
 
 func read(filepath string) (string, error) {
@@ -99,11 +314,98 @@ func getTokens(c rune) bool {
 	return c == ' ' || c == '\t'
 }
 
+// tokenize split the line like `getTokens`, but a text between quotes is kept in one token
+func tokenize(line string) []string {
+	var tokens []string
+	for _, span := range tokenSpans(line) {
+		tokens = append(tokens, line[span[0]:span[1]])
+	}
+	return tokens
+}
+
+// tokenSpans the start and end of each token of `tokenize` in the line
+func tokenSpans(line string) [][2]int {
+	var spans [][2]int
+	start := -1
+	quoted := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quoted:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				quoted = false
+			}
+			break
+		case getTokens(rune(c)):
+			if start >= 0 {
+				spans = append(spans, [2]int{start, i})
+				start = -1
+			}
+			break
+		case start < 0:
+			start = i
+			quoted = c == '"'
+			break
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(line)})
+	}
+	return spans
+}
+
+const (
+	LANG_EN = "en"
+	LANG_PT = "pt"
+)
+
+// Keywords the words used by the instructions, each language has its own set
+type Keywords struct {
+	to     string
+	iff    string
+	write  string
+	read   string
+	assert string
+	sys    string
+}
+
+var KEYWORDS = map[string]Keywords{
+	LANG_EN: {to: "to", iff: "if", write: "write", read: "read", assert: "assert", sys: "sys"},
+	LANG_PT: {to: "para", iff: "se", write: "escreva", read: "leia", assert: "afirme", sys: "sis"},
+}
+
+// hasLangPragma if follow this pattern `#lang {en, pt}`
+func hasLangPragma(tokens []string) (string, bool) {
+	if len(tokens) < 2 || tokens[0] != "#lang" {
+		return "", false
+	}
+	return tokens[1], true
+}
+
+// getKeywords find the keywords selected by the first `#lang` pragma, english is the default
+func getKeywords(lines []string) (Keywords, error) {
+	for iline, line := range lines {
+		tokens := strings.FieldsFunc(line, getTokens)
+		if lang, exists := hasLangPragma(tokens); exists {
+			kw, ok := KEYWORDS[lang]
+			if !ok {
+				return Keywords{}, compilationError(iline+1, formatError("lang", I18N_COMPILE_ERR_LANG_NOT_FOUND, lang))
+			}
+			return kw, nil
+		}
+	}
+	return KEYWORDS[LANG_EN], nil
+}
+
 const (
 	INST_OP = iota
 	INST_TO
 	INST_WRITE
 	INST_READ
+	INST_ASSERT
+	INST_SYS
 )
 
 type Instruction struct {
@@ -231,8 +533,8 @@ type Operation struct {
 }
 
 // hasOperationInst if follow this pattern `$v = $1 {-, +, *, /} $2`
-func hasOperationInst(tokens []string) (*Instruction, error) {
-	if tokens[1] != "=" {
+func hasOperationInst(kw Keywords, tokens []string) (*Instruction, error) {
+	if len(tokens) < 2 || tokens[1] != "=" {
 		for i, token := range tokens[1:] {
 			if token == "=" {
 				return nil, formatError("op", I18N_ERR_OP_ONLY_ONE_LEFT_VAL, tokens[:i])
@@ -246,6 +548,9 @@ func hasOperationInst(tokens []string) (*Instruction, error) {
 	if v == nil || v.typ == VAL_CONST {
 		return nil, formatError("op", I18N_ERR_OP_LEFT_VAL_INVALID, tokens[0])
 	}
+	if len(tokens) < 3 {
+		return nil, formatError("op", I18N_ERR_MISSING_PARAM, tokens)
+	}
 
 	v1 := hasValue(tokens[2])
 	if v1 == nil {
@@ -260,6 +565,9 @@ func hasOperationInst(tokens []string) (*Instruction, error) {
 	if !exists {
 		return nil, formatError("op", I18N_ERR_OP_OP_INVALID, tokens[3])
 	}
+	if len(tokens) < 5 {
+		return nil, formatError("op", I18N_ERR_MISSING_PARAM, tokens)
+	}
 
 	v2 := hasValue(tokens[4])
 	if v2 == nil {
@@ -279,10 +587,13 @@ type IfInst struct {
 }
 
 // hasToInst if first token is a 'to' and second is a label
-func hasToInst(tokens []string) (*Instruction, error) {
-	if tokens[0] != "to" {
+func hasToInst(kw Keywords, tokens []string) (*Instruction, error) {
+	if tokens[0] != kw.to {
 		return nil, nil
 	}
+	if len(tokens) < 2 {
+		return nil, formatError("to", I18N_ERR_MISSING_PARAM, tokens)
+	}
 	if !isWord(tokens[1]) {
 		return nil, formatError("to", I18N_ERR_TO_INVALID_WORD, tokens[1])
 	}
@@ -290,8 +601,8 @@ func hasToInst(tokens []string) (*Instruction, error) {
 	var moveIf []interface{} = nil
 	var err error
 	if len(tokens) > 2 {
-		if tokens[2] == "if" {
-			moveIf, err = compileIf(tokens[2:])
+		if tokens[2] == kw.iff {
+			moveIf, err = compileIf(kw, tokens[2:])
 			if err != nil {
 				return nil, err
 			}
@@ -356,7 +667,7 @@ const (
 
 // ifInstOrder try to get the if instruction order from code
 func ifInstOrder(i int) int {
-	if i > 0 && i%4 == 0 {
+	if i%4 == 3 {
 		return IFO_LOP
 	} else if i%2 == 0 {
 		return IFO_VAL
@@ -365,17 +676,30 @@ func ifInstOrder(i int) int {
 }
 
 // compileIf if follow this pattern `if $1 {==, !=, >, <, >=, <=} $2 {&&, ||} ... then $n`
-func compileIf(tokens []string) ([]interface{}, error) {
-	if tokens[0] != "if" {
+func compileIf(kw Keywords, tokens []string) ([]interface{}, error) {
+	if tokens[0] != kw.iff {
 		return nil, formatError("if", I18N_ERR_IF_EXPECT_IF, tokens[0])
 	}
+	if len(tokens) < 2 {
+		return nil, formatError("if", I18N_ERR_MISSING_PARAM, tokens)
+	}
+
+	params, rest, err := compileCondition(tokens[1:])
+	if err != nil {
+		return nil, err
+	}
+	if !isCommentInst(rest) {
+		return nil, formatError("if", I18N_ERR_IF_EXPECT_VALUE, rest[0])
+	}
+	return params, nil
+}
 
+// compileCondition compile the comparisons until a comment or a message between quotes, the tokens
+// after the condition are returned
+func compileCondition(tokens []string) ([]interface{}, []string, error) {
 	var params []interface{}
-	for i, token := range tokens[1:] {
-		if isCommentInst(tokens[i:]) {
-			if ifInstOrder(i-1) != IFO_VAL {
-				return nil, formatError("if", I18N_ERR_IF_EXPECT_END_WITH_VALUE, tokens[i-1])
-			}
+	for i, token := range tokens {
+		if isCommentInst(tokens[i:]) || strings.HasPrefix(token, "\"") {
 			break
 		}
 
@@ -407,22 +731,60 @@ func compileIf(tokens []string) ([]interface{}, error) {
 		}
 
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		params = append(params, p)
 	}
 
-	if ifInstOrder(len(tokens)-2) != IFO_VAL {
-		return nil, formatError("if", I18N_ERR_IF_EXPECT_END_WITH_VALUE, tokens[len(tokens)-1])
+	// every comparison needs its two values, so a lone value like `if 0` is incomplete
+	if len(params)%4 != 3 {
+		last := ""
+		if len(params) > 0 {
+			last = tokens[len(params)-1]
+		}
+		return nil, nil, formatError("if", I18N_ERR_IF_EXPECT_END_WITH_VALUE, last)
 	}
 
-	return params, nil
+	return params, tokens[len(params):], nil
+}
+
+// AssertInst a condition that must be true, the message is optional
+type AssertInst struct {
+	cond    []interface{}
+	message string
 }
 
-func hasWriteInst(tokens []string) (*Instruction, error) {
-	if tokens[0] != "write" {
+// hasAssertInst will follow the pattern `assert condition "message"?`
+func hasAssertInst(kw Keywords, tokens []string) (*Instruction, error) {
+	if tokens[0] != kw.assert {
 		return nil, nil
 	}
+	if len(tokens) < 2 {
+		return nil, formatError("assert", I18N_ERR_MISSING_PARAM, tokens)
+	}
+
+	cond, rest, err := compileCondition(tokens[1:])
+	if err != nil {
+		return nil, err
+	}
+	message := ""
+	if !isCommentInst(rest) {
+		message, err = strconv.Unquote(rest[0])
+		if err != nil || !isCommentInst(rest[1:]) {
+			return nil, formatError("assert", I18N_ERR_ASSERT_EXPECT_MESSAGE, rest)
+		}
+	}
+
+	return &Instruction{typ: INST_ASSERT, val: AssertInst{cond: cond, message: message}}, nil
+}
+
+func hasWriteInst(kw Keywords, tokens []string) (*Instruction, error) {
+	if tokens[0] != kw.write {
+		return nil, nil
+	}
+	if len(tokens) < 2 {
+		return nil, formatError("write", I18N_ERR_MISSING_PARAM, tokens)
+	}
 	v1 := hasValue(tokens[1])
 	if v1 == nil {
 		return nil, formatError("write", I18N_ERR_WRITE_EXPECT_VALUE, tokens[1])
@@ -440,15 +802,21 @@ type ReadInst struct {
 }
 
 // hasReadInst will follow the pattern `read $ label?`
-func hasReadInst(tokens []string) (*Instruction, error) {
-	if tokens[0] != "read" {
+func hasReadInst(kw Keywords, tokens []string) (*Instruction, error) {
+	if tokens[0] != kw.read {
 		return nil, nil
 	}
+	if len(tokens) < 2 {
+		return nil, formatError("read", I18N_ERR_MISSING_PARAM, tokens)
+	}
 
 	t := hasValue(tokens[1])
 	if t == nil {
 		return nil, formatError("read", I18N_ERR_READ_EXPECT_VALUE, tokens[1])
 	}
+	if t.typ == VAL_CONST {
+		return nil, formatError("read", I18N_ERR_READ_TARGET_INVALID, tokens[1])
+	}
 	label := ""
 	if len(tokens) > 2 {
 		if !isWord(tokens[2]) {
@@ -460,31 +828,50 @@ func hasReadInst(tokens []string) (*Instruction, error) {
 	return &Instruction{typ: INST_READ, val: ReadInst{target: *t, elseLabel: label}}, nil
 }
 
-type InstFunc func(tokens []string) (*Instruction, error)
+type InstFunc func(kw Keywords, tokens []string) (*Instruction, error)
 
 // WARN: the order here matters, check the first error for `hasOperationInst` and `hasToInst` to understand why.
-var INSTRUCTIONS = []InstFunc{hasToInst, hasWriteInst, hasOperationInst, hasReadInst}
+var INSTRUCTIONS = []InstFunc{hasToInst, hasWriteInst, hasAssertInst, hasSysInst, hasOperationInst, hasReadInst}
 
 type Program struct {
 	labels       map[string]int
 	instructions []Instruction
 }
 
+// LineError an error found at a line of the source
+type LineError struct {
+	template string
+	line     int
+	err      error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf(e.template, e.line, e.err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.err
+}
+
 func compilationError(line int, err error) error {
-	return fmt.Errorf(I18N_COMPILE_ERR_TEMPLATE, line, err)
+	return &LineError{template: I18N_COMPILE_ERR_TEMPLATE, line: line, err: err}
 }
 
 func compile(code string) (*Program, error) {
 	var instructions []Instruction
 	labels := make(map[string]int)
 	lines := strings.Split(code, "\n")
+	kw, err := getKeywords(lines)
+	if err != nil {
+		return nil, err
+	}
 
 	for iline, line := range lines {
 		line = strings.TrimSpace(line)
 		if len(line) == 0 {
 			continue
 		}
-		tokens := strings.FieldsFunc(line, getTokens)
+		tokens := tokenize(line)
 		if isCommentInst(tokens) {
 			continue
 		}
@@ -493,9 +880,9 @@ func compile(code string) (*Program, error) {
 		} else {
 			hasError := true
 			for _, f := range INSTRUCTIONS {
-				inst, err := f(tokens)
+				inst, err := f(kw, tokens)
 				if err != nil {
-					return nil, compilationError(iline, err)
+					return nil, compilationError(iline+1, err)
 				}
 				if inst != nil {
 					hasError = false
@@ -505,28 +892,36 @@ func compile(code string) (*Program, error) {
 				}
 			}
 			if hasError {
-				return nil, compilationError(iline, formatError("?", I18N_COMPILE_ERR_INST_NOT_FOUND, tokens))
+				return nil, compilationError(iline+1, formatError("?", I18N_COMPILE_ERR_INST_NOT_FOUND, tokens))
 			}
 		}
 	}
 
+	if err := checkLabels(instructions, labels); err != nil {
+		return nil, err
+	}
+
+	return &Program{instructions: instructions, labels: labels}, nil
+}
+
+// checkLabels if every label used by the instructions is defined
+func checkLabels(instructions []Instruction, labels map[string]int) error {
 	for _, inst := range instructions {
 		if inst.typ == INST_TO {
 			k := inst.val.(IfInst).target
 			if _, ok := labels[k]; !ok {
-				return nil, compilationError(inst.line, formatError("label", I18N_COMPILE_ERR_LABEL_NOT_FOUND, k))
+				return compilationError(inst.line, formatError("label", I18N_COMPILE_ERR_LABEL_NOT_FOUND, k))
 			}
 		} else if inst.typ == INST_READ {
 			k := inst.val.(ReadInst).elseLabel
 			if k != "" {
 				if _, ok := labels[k]; !ok {
-					return nil, compilationError(inst.line, formatError("label", I18N_COMPILE_ERR_LABEL_NOT_FOUND, k))
+					return compilationError(inst.line, formatError("label", I18N_COMPILE_ERR_LABEL_NOT_FOUND, k))
 				}
 			}
 		}
 	}
-
-	return &Program{instructions: instructions, labels: labels}, nil
+	return nil
 }
 
 func valueFromMem(mem []int64, val InstValue) (int64, error) {
@@ -544,8 +939,19 @@ func valueFromMem(mem []int64, val InstValue) (int64, error) {
 	panic("IMPOSSIBLE")
 }
 
+// addressFromMem get the slot written by a variable|reference
+func addressFromMem(mem []int64, val InstValue) (int64, error) {
+	if val.typ == VAL_REF {
+		if mem[val.val] < 0 || mem[val.val] > 1023 {
+			return 0, formatError("[memory]", I18N_EXEC_ERR_INVALID_MEMORY_ACCESS, val.val)
+		}
+		return mem[val.val], nil
+	}
+	return val.val, nil
+}
+
 func executionError(line int, err error) error {
-	return fmt.Errorf(I18N_EXEC_ERR_TEMPLATE, line, err)
+	return &LineError{template: I18N_EXEC_ERR_TEMPLATE, line: line, err: err}
 }
 
 func executeIf(mem []int64, inst Instruction) (res bool, err error) {
@@ -553,15 +959,20 @@ func executeIf(mem []int64, inst Instruction) (res bool, err error) {
 	if moveIf == nil {
 		return true, nil
 	}
-	for i := 0; i < len(moveIf); i += 4 {
-		v1, err := valueFromMem(mem, moveIf[i].(InstValue))
+	return evaluateCondition(mem, inst.line, moveIf)
+}
+
+// evaluateCondition evaluate every comparison of the condition, then combine them from left to right
+func evaluateCondition(mem []int64, line int, cond []interface{}) (res bool, err error) {
+	for i := 0; i < len(cond); i += 4 {
+		v1, err := valueFromMem(mem, cond[i].(InstValue))
 		if err != nil {
-			return false, executionError(inst.line, err)
+			return false, executionError(line, err)
 		}
-		cp := moveIf[i+1].(int64)
-		v2, err := valueFromMem(mem, moveIf[i+2].(InstValue))
+		cp := cond[i+1].(int64)
+		v2, err := valueFromMem(mem, cond[i+2].(InstValue))
 		if err != nil {
-			return false, executionError(inst.line, err)
+			return false, executionError(line, err)
 		}
 
 		var r bool
@@ -587,7 +998,7 @@ func executeIf(mem []int64, inst Instruction) (res bool, err error) {
 		}
 
 		if i > 0 {
-			switch moveIf[i-1].(int64) {
+			switch cond[i-1].(int64) {
 			case LOP_AND:
 				res = res && r
 				break
@@ -603,6 +1014,52 @@ func executeIf(mem []int64, inst Instruction) (res bool, err error) {
 	return res, nil
 }
 
+// AssertError an assertion that was false, with the values read by its condition
+type AssertError struct {
+	message string
+	values  []string
+}
+
+func (e *AssertError) Error() string {
+	problem := e.message
+	if len(e.values) > 0 {
+		problem += " (" + strings.Join(e.values, ", ") + ")"
+	}
+	return formatError("[assert]", I18N_EXEC_ERR_ASSERT, problem).Error()
+}
+
+// conditionValues the text of every variable and reference read by the condition, each one once
+func conditionValues(mem []int64, cond []interface{}) []string {
+	var values []string
+	seen := make(map[InstValue]bool)
+	for i, p := range cond {
+		if ifInstOrder(i) != IFO_VAL {
+			continue
+		}
+		v := p.(InstValue)
+		if v.typ == VAL_CONST || seen[v] {
+			continue
+		}
+		seen[v] = true
+		if v.typ == VAL_VAR {
+			values = append(values, fmt.Sprintf("$%d = %d", v.val, mem[v.val]))
+		} else {
+			values = append(values, fmt.Sprintf("&%d -> %d = %d", v.val, mem[v.val], mem[mem[v.val]]))
+		}
+	}
+	return values
+}
+
+// assertionError the error of a false assertion, without a message the condition is shown
+func assertionError(mem []int64, inst Instruction) error {
+	a := inst.val.(AssertInst)
+	message := a.message
+	if message == "" {
+		message = conditionText(a.cond)
+	}
+	return executionError(inst.line, &AssertError{message: message, values: conditionValues(mem, a.cond)})
+}
+
 type WriteResult struct {
 	val InstValue
 	ref int64
@@ -621,133 +1078,213 @@ func (w WriteResult) ToString() string {
 	panic("IMPOSSIBLE")
 }
 
-func execute(prog Program, input []int64) ([]WriteResult, error) {
-	var results []WriteResult
-	pc := 0
-	rc := 0
-	mem := make([]int64, 1024)
-
-	for pc < len(prog.instructions) {
-		switch prog.instructions[pc].typ {
-		case INST_OP:
-			op := prog.instructions[pc].val.(Operation)
-			v1, err := valueFromMem(mem, op.v1)
-			if err != nil {
-				return results, executionError(prog.instructions[pc].line, err)
+type VM struct {
+	prog    Program
+	mem     []int64
+	input   []int64
+	results []WriteResult
+	pc      int
+	rc      int
+	// jumped if the last step moved to a label instead of the next instruction
+	jumped bool
+	// steps how many instructions were executed, at most maxSteps when it isn't 0
+	steps    int
+	maxSteps int
+	// cycles the cost of the instructions executed, counted only when there is a cost table
+	costs  *CostTable
+	cycles int64
+	// history the steps that can be undone by `back`, kept only when it isn't nil
+	history *History
+	// syscalls the handlers of `sys`, a copy of `SYSCALLS` so `registerSyscall` only changes this VM
+	syscalls map[string]Syscall
+}
+
+func newVM(prog Program, input []int64) *VM {
+	syscalls := make(map[string]Syscall, len(SYSCALLS))
+	for name, s := range SYSCALLS {
+		syscalls[name] = s
+	}
+	return &VM{prog: prog, mem: make([]int64, MEMORY_SIZE), input: input, syscalls: syscalls}
+}
+
+// done if there is no more instructions to execute
+func (vm *VM) done() bool {
+	return vm.pc >= len(vm.prog.instructions)
+}
+
+// run execute the instructions until the program ends or fails
+func (vm *VM) run() error {
+	for !vm.done() {
+		if err := vm.step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// step execute the instruction pointed by `pc`, counting the steps and the cycles
+func (vm *VM) step() error {
+	vm.jumped = false
+	if vm.maxSteps > 0 && vm.steps >= vm.maxSteps {
+		return stepLimitError(vm.prog.instructions[vm.pc].line, vm.maxSteps)
+	}
+	if vm.history != nil {
+		vm.history.record(vm)
+	}
+	vm.steps += 1
+	if vm.costs == nil {
+		return vm.exec()
+	}
+	inst := vm.prog.instructions[vm.pc]
+	err := vm.exec()
+	if err == nil {
+		vm.cycles += vm.costs.cycles(inst, vm.jumped)
+	}
+	return err
+}
+
+// exec execute the instruction pointed by `pc`, without the limits and the costs of `step`
+func (vm *VM) exec() error {
+	switch vm.prog.instructions[vm.pc].typ {
+	case INST_OP:
+		op := vm.prog.instructions[vm.pc].val.(Operation)
+		v1, err := valueFromMem(vm.mem, op.v1)
+		if err != nil {
+			return executionError(vm.prog.instructions[vm.pc].line, err)
+		}
+		v2, err := valueFromMem(vm.mem, op.v2)
+		if err != nil {
+			return executionError(vm.prog.instructions[vm.pc].line, err)
+		}
+		addr, err := addressFromMem(vm.mem, op.v)
+		if err != nil {
+			return executionError(vm.prog.instructions[vm.pc].line, err)
+		}
+		switch op.op {
+		case OP_UNI:
+			vm.mem[addr] = v1
+			break
+		case OP_ADD:
+			vm.mem[addr] = v1 + v2
+			break
+		case OP_SUB:
+			vm.mem[addr] = v1 - v2
+			break
+		case OP_MUL:
+			vm.mem[addr] = v1 * v2
+			break
+		case OP_DIV:
+			if v2 == 0 {
+				return divisionByZeroError(vm.prog.instructions[vm.pc].line)
 			}
-			v2, err := valueFromMem(mem, op.v2)
+			vm.mem[addr] = v1 / v2
+			break
+		}
+		vm.pc += 1
+	case INST_TO:
+		i := vm.prog.instructions[vm.pc].val.(IfInst)
+		c, err := executeIf(vm.mem, vm.prog.instructions[vm.pc])
+		if err != nil {
+			return err
+		}
+		if c {
+			vm.pc = vm.prog.labels[i.target]
+			vm.jumped = true
+		} else {
+			vm.pc += 1
+		}
+		break
+	case INST_WRITE:
+		val := vm.prog.instructions[vm.pc].val.(InstValue)
+		switch val.typ {
+		case VAL_CONST:
+			vm.results = append(vm.results, WriteResult{val: val})
+			break
+		case VAL_VAR:
+			v, err := valueFromMem(vm.mem, val)
 			if err != nil {
-				return results, executionError(prog.instructions[pc].line, err)
-			}
-			switch op.op {
-			case OP_UNI:
-				if op.v.typ == VAL_REF {
-					mem[mem[op.v.val]] = v1
-				} else {
-					mem[op.v.val] = v1
-				}
-				break
-			case OP_ADD:
-				if op.v.typ == VAL_REF {
-					mem[mem[op.v.val]] = v1 + v2
-				} else {
-					mem[op.v.val] = v1 + v2
-				}
-				break
-			case OP_SUB:
-				if op.v.typ == VAL_REF {
-					mem[mem[op.v.val]] = v1 - v2
-				} else {
-					mem[op.v.val] = v1 - v2
-				}
-				break
-			case OP_MUL:
-				if op.v.typ == VAL_REF {
-					mem[mem[op.v.val]] = v1 * v2
-				} else {
-					mem[op.v.val] = v1 * v2
-				}
-				break
-			case OP_DIV:
-				if op.v.typ == VAL_REF {
-					mem[mem[op.v.val]] = v1 / v2
-				} else {
-					mem[op.v.val] = v1 / v2
-				}
-				break
+				return executionError(vm.prog.instructions[vm.pc].line, err)
+			} else {
+				vm.results = append(vm.results, WriteResult{val: val, res: v})
 			}
-			pc += 1
-		case INST_TO:
-			i := prog.instructions[pc].val.(IfInst)
-			c, err := executeIf(mem, prog.instructions[pc])
+			break
+		case VAL_REF:
+			v, err := valueFromMem(vm.mem, val)
 			if err != nil {
-				return results, err
-			}
-			if c {
-				pc = prog.labels[i.target]
+				return executionError(vm.prog.instructions[vm.pc].line, err)
 			} else {
-				pc += 1
+				vm.results = append(vm.results, WriteResult{val: val, ref: vm.mem[val.val], res: v})
 			}
 			break
-		case INST_WRITE:
-			val := prog.instructions[pc].val.(InstValue)
-			switch val.typ {
-			case VAL_CONST:
-				results = append(results, WriteResult{val: val})
-				break
-			case VAL_VAR:
-				v, err := valueFromMem(mem, val)
-				if err != nil {
-					return results, executionError(prog.instructions[pc].line, err)
-				} else {
-					results = append(results, WriteResult{val: val, res: v})
-				}
-				break
-			case VAL_REF:
-				v, err := valueFromMem(mem, val)
-				if err != nil {
-					return results, executionError(prog.instructions[pc].line, err)
-				} else {
-					results = append(results, WriteResult{val: val, ref: mem[val.val], res: v})
-				}
-				break
+		}
+		vm.pc += 1
+		break
+	case INST_READ:
+		in := vm.prog.instructions[vm.pc].val.(ReadInst)
+		if vm.rc < len(vm.input) {
+			addr, err := addressFromMem(vm.mem, in.target)
+			if err != nil {
+				return executionError(vm.prog.instructions[vm.pc].line, err)
 			}
-			pc += 1
-			break
-		case INST_READ:
-			in := prog.instructions[pc].val.(ReadInst)
-			if rc < len(input) {
-				if in.target.typ == VAL_REF {
-					mem[mem[in.target.val]] = input[rc]
-				} else {
-					mem[in.target.val] = input[rc]
-				}
-				rc += 1
-				pc += 1
-			} else {
-				if in.elseLabel == "" {
-					return results, executionError(prog.instructions[pc].line, formatError("[read]", I18N_ERR_READ_NOTHING, I18N_ERR_READ_NO_ELSE_LABEL))
-				}
-				pc = prog.labels[in.elseLabel]
+			vm.mem[addr] = vm.input[vm.rc]
+			vm.rc += 1
+			vm.pc += 1
+		} else {
+			if in.elseLabel == "" {
+				return executionError(vm.prog.instructions[vm.pc].line, formatError("[read]", I18N_ERR_READ_NOTHING, I18N_ERR_READ_NO_ELSE_LABEL))
 			}
-			break
+			vm.pc = vm.prog.labels[in.elseLabel]
+			vm.jumped = true
+		}
+		break
+	case INST_ASSERT:
+		inst := vm.prog.instructions[vm.pc]
+		c, err := evaluateCondition(vm.mem, inst.line, inst.val.(AssertInst).cond)
+		if err != nil {
+			return err
+		}
+		if !c {
+			return assertionError(vm.mem, inst)
 		}
+		vm.pc += 1
+		break
+	case INST_SYS:
+		if err := vm.syscall(vm.prog.instructions[vm.pc]); err != nil {
+			return err
+		}
+		vm.pc += 1
+		break
 	}
+	return nil
+}
 
-	return results, nil
+func divisionByZeroError(line int) error {
+	return executionError(line, formatError("[op]", I18N_EXEC_ERR_DIVISION_BY_ZERO, 0))
 }
 
-func Run(source string, input string) ([]WriteResult, error) {
-	sdat, err := read(source)
-	if err != nil {
-		return []WriteResult{}, err
-	}
+func stepLimitError(line int, maxSteps int) error {
+	return executionError(line, formatError("[limit]", I18N_EXEC_ERR_STEP_LIMIT, maxSteps))
+}
+
+func execute(prog Program, input []int64) ([]WriteResult, error) {
+	return executeBounded(prog, input, 0)
+}
 
+// executeBounded execute at most `maxSteps` instructions, 0 is no limit
+func executeBounded(prog Program, input []int64, maxSteps int) ([]WriteResult, error) {
+	vm := newVM(prog, input)
+	vm.maxSteps = maxSteps
+	err := vm.run()
+	return vm.results, err
+}
+
+// readInput the numbers of the input file, one per line
+func readInput(input string) ([]int64, error) {
 	var ivalues []int64
 	if input != "" {
 		idat, err := read(input)
 		if err != nil {
-			return []WriteResult{}, err
+			return nil, err
 		}
 		lines := strings.Split(idat, "\n")
 		ivalues = make([]int64, len(lines))
@@ -755,21 +1292,155 @@ func Run(source string, input string) ([]WriteResult, error) {
 			v = strings.TrimSpace(v)
 			ivalues[i], err = strconv.ParseInt(v, 10, 64)
 			if err != nil {
-				i18n.Printf(I18N_INPUT_ERR_TEMPLATE, input, i+1, v)
-				os.Exit(1)
+				return nil, errors.New(strings.TrimSuffix(i18n.Sprintf(I18N_INPUT_ERR_TEMPLATE, input, i+1, v), "\n"))
 			}
 		}
 	}
-	prog, err := compile(sdat)
+	return ivalues, nil
+}
+
+func Run(source string, input string) ([]WriteResult, error) {
+	ivalues, err := readInput(input)
+	if err != nil {
+		return []WriteResult{}, err
+	}
+	prog, err := loadProgram(source)
 	if err != nil {
 		return []WriteResult{}, err
 	}
 	return execute(*prog, ivalues)
 }
 
+// runRun the `run` command, execute a source or bytecode file
+func runRun(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	optimized := flags.Bool("O", false, i18n.Sprintf(I18N_FLAG_OPTIMIZE))
+	engine := flags.String("engine", ENGINE_SWITCH, i18n.Sprintf(I18N_FLAG_ENGINE))
+	noAssert := flags.Bool("no-assert", false, i18n.Sprintf(I18N_FLAG_NO_ASSERT))
+	maxSteps := flags.Int("max-steps", 0, i18n.Sprintf(I18N_FLAG_MAX_STEPS))
+	stats := flags.Bool("stats", false, i18n.Sprintf(I18N_FLAG_STATS))
+	costsFile := flags.String("costs", "", i18n.Sprintf(I18N_FLAG_COSTS))
+	record := flags.String("record", "", i18n.Sprintf(I18N_FLAG_RECORD))
+	dumpMem := flags.String("dump-mem", "", i18n.Sprintf(I18N_FLAG_DUMP_MEM))
+	loadMem := flags.String("load-mem", "", i18n.Sprintf(I18N_FLAG_LOAD_MEM))
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		printUsage()
+		return 0
+	}
+	if flags.NArg() > 2 {
+		i18n.Println(I18N_ERR_PROG_TOO_MANY_PARMS)
+		return 1
+	}
+	if !isProgramFile(flags.Arg(0)) {
+		i18n.Println(I18N_ERR_PROG_NEED_ASM_EXT)
+		return 1
+	}
+
+	prog, err := loadProgram(flags.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if *noAssert {
+		*prog = removeAsserts(*prog)
+	}
+	if *optimized {
+		*prog = optimize(*prog)
+	}
+	input, err := readInput(flags.Arg(1))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	if *engine != ENGINE_SWITCH && *engine != ENGINE_CLOSURE {
+		i18n.Printf(I18N_ERR_ENGINE_NOT_FOUND, *engine)
+		return 1
+	}
+
+	if *costsFile != "" && !*stats {
+		i18n.Println(I18N_ERR_COSTS_STATS)
+		return 1
+	}
+	if *record != "" && *loadMem != "" {
+		i18n.Println(I18N_SNAPSHOT_ERR_RECORD)
+		return 1
+	}
+
+	// the cycles, the recording and the snapshots need the VM of the switch engine
+	vmOnly := *stats || *record != "" || *dumpMem != "" || *loadMem != ""
+	if vmOnly && *engine != ENGINE_SWITCH {
+		i18n.Println(I18N_ERR_ENGINE_SWITCH)
+		return 1
+	}
+
+	var res []WriteResult
+	var st Stats
+	if vmOnly {
+		vm := newVM(*prog, input)
+		vm.maxSteps = *maxSteps
+		if *stats {
+			costs, cerr := loadCosts(*costsFile)
+			if cerr != nil {
+				fmt.Println(cerr)
+				return 1
+			}
+			vm.costs = costs
+		}
+		var syscalls *SyscallLog
+		if *record != "" {
+			syscalls = recordSyscalls(vm)
+		}
+		if *loadMem != "" {
+			snap, serr := loadSnapshot(*loadMem)
+			if serr == nil {
+				serr = vm.restore(*snap)
+			}
+			if serr != nil {
+				fmt.Printf("%s: %v\n", *loadMem, serr)
+				return 1
+			}
+		}
+		err = vm.run()
+		res = vm.results
+		st = vm.stats()
+		if *record != "" {
+			if rerr := saveRecording(*record, newRecording(vm, syscalls, err)); rerr != nil {
+				fmt.Println(rerr)
+				return 1
+			}
+		}
+		if *dumpMem != "" {
+			if serr := saveSnapshot(*dumpMem, newSnapshot(vm, err)); serr != nil {
+				fmt.Println(serr)
+				return 1
+			}
+		}
+	} else {
+		res, err = executeWith(*engine, *prog, input, *maxSteps)
+	}
+	for _, r := range res {
+		print(r)
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+	if *stats {
+		i18n.Fprintf(os.Stderr, I18N_RUN_STATS, st.Steps, st.Cycles, st.Size)
+	}
+	if err != nil {
+		return 1
+	}
+	return 0
+}
+
 func init() {
 	message.SetString(language.BrazilianPortuguese, I18N_ERR_PROG_TOO_MANY_PARMS, "Muitos parametros")
-	message.SetString(language.BrazilianPortuguese, I18N_ERR_PROG_NEED_ASM_EXT, "Arquivo deve ter a extensão .asm")
+	message.SetString(language.BrazilianPortuguese, I18N_ERR_PROG_NEED_ASM_EXT, "Arquivo deve ter a extensão .asm, .lst ou .fbc")
+
+	message.SetString(language.BrazilianPortuguese, I18N_ERR_MISSING_PARAM, "falta um parâmetro depois de")
 
 	message.SetString(language.BrazilianPortuguese, I18N_ERR_OP_ONLY_ONE_LEFT_VAL, "deve ter apenas um valor no lado esquerdo da operação, mas recebeu")
 	message.SetString(language.BrazilianPortuguese, I18N_ERR_OP_LEFT_VAL_INVALID, "valor esquerdo da operação inválido")
@@ -790,21 +1461,155 @@ func init() {
 	message.SetString(language.BrazilianPortuguese, I18N_ERR_WRITE_ONLY_ONE_PARAM, "recebe apenas um valor como parametro, mas recebeu")
 
 	message.SetString(language.BrazilianPortuguese, I18N_ERR_READ_EXPECT_VALUE, "espera um valor, mas recebeu")
+	message.SetString(language.BrazilianPortuguese, I18N_ERR_READ_TARGET_INVALID, "destino da leitura inválido")
 	message.SetString(language.BrazilianPortuguese, I18N_ERR_READ_INVALID_WORD, "esperando uma palavra válida, mas recebeu")
 	message.SetString(language.BrazilianPortuguese, I18N_ERR_READ_NOTHING, "tentando ler um arquivo que já acabou")
 	message.SetString(language.BrazilianPortuguese, I18N_ERR_READ_NO_ELSE_LABEL, "sem uma label de saída")
 
+	message.SetString(language.BrazilianPortuguese, I18N_ERR_ASSERT_EXPECT_MESSAGE, "espera uma mensagem entre aspas depois da condição, mas recebeu")
+
+	message.SetString(language.BrazilianPortuguese, I18N_ERR_SYS_INVALID_WORD, "esperando o nome de uma chamada de sistema, mas recebeu")
+	message.SetString(language.BrazilianPortuguese, I18N_ERR_SYS_NOT_FOUND, "chamada de sistema não encontrada")
+	message.SetString(language.BrazilianPortuguese, I18N_ERR_SYS_ARITY, "quantidade errada de argumentos, esperando")
+	message.SetString(language.BrazilianPortuguese, I18N_ERR_SYS_NO_TRANSPILE, "uma chamada de sistema não pode ser transpilada")
+
 	message.SetString(language.BrazilianPortuguese, I18N_COMPILE_ERR_TEMPLATE, "[Erro de compilação : linha %d] %v.")
 
 	message.SetString(language.BrazilianPortuguese, I18N_COMPILE_ERR_INST_NOT_FOUND, "instrução não identificada")
 	message.SetString(language.BrazilianPortuguese, I18N_COMPILE_ERR_LABEL_NOT_FOUND, "label não foi definida")
+	message.SetString(language.BrazilianPortuguese, I18N_COMPILE_ERR_LANG_NOT_FOUND, "linguagem não suportada")
 
 	message.SetString(language.BrazilianPortuguese, I18N_EXEC_ERR_INVALID_MEMORY_ACCESS, "acesso de memória inválido")
+	message.SetString(language.BrazilianPortuguese, I18N_EXEC_ERR_ASSERT, "asserção falhou")
+	message.SetString(language.BrazilianPortuguese, I18N_EXEC_ERR_DIVISION_BY_ZERO, "divisão por zero")
+	message.SetString(language.BrazilianPortuguese, I18N_EXEC_ERR_STEP_LIMIT, "instruções demais executadas, o limite é")
+	message.SetString(language.BrazilianPortuguese, I18N_EXEC_ERR_OUTPUT_LIMIT, "resultados demais, o limite é")
+	message.SetString(language.BrazilianPortuguese, I18N_EXEC_ERR_TIMEOUT, "o programa executou por mais tempo que")
+	message.SetString(language.BrazilianPortuguese, I18N_EXEC_ERR_SYS_SET, "a chamada de sistema não pode escrever em um argumento constante")
+	message.SetString(language.BrazilianPortuguese, I18N_EXEC_ERR_SYS_RANGE, "o máximo é menor que o mínimo ou o intervalo é grande demais")
 
 	message.SetString(language.BrazilianPortuguese, I18N_EXEC_ERR_TEMPLATE, "[Erro de execução : linha %d] %v.")
 
 	message.SetString(language.BrazilianPortuguese, I18N_INPUT_ERR_TEMPLATE, "No arquivo de entrada '%s' na linha %d não foi possivel converter '%s' em um número\n")
 
+	message.SetString(language.BrazilianPortuguese, I18N_FMT_FLAG_CHECK, "apenas verifica se os arquivos estão formatados")
+	message.SetString(language.BrazilianPortuguese, I18N_FMT_FLAG_WRITE, "escreve o resultado no arquivo ao invés de imprimir")
+	message.SetString(language.BrazilianPortuguese, I18N_FMT_NOT_FORMATTED, "%s não está formatado\n")
+	message.SetString(language.BrazilianPortuguese, I18N_FMT_ERR_CHANGED, "formatar mudaria o programa")
+
+	message.SetString(language.BrazilianPortuguese, I18N_BUILD_FLAG_OUTPUT, "arquivo de saída do bytecode")
+
+	message.SetString(language.BrazilianPortuguese, I18N_BYTECODE_ERR_MAGIC, "não é um arquivo de bytecode")
+	message.SetString(language.BrazilianPortuguese, I18N_BYTECODE_ERR_VERSION, "versão de bytecode não suportada")
+	message.SetString(language.BrazilianPortuguese, I18N_BYTECODE_ERR_INVALID, "bytecode inválido")
+	message.SetString(language.BrazilianPortuguese, I18N_BYTECODE_ERR_JUMP, "destino de salto inválido")
+
+	message.SetString(language.BrazilianPortuguese, I18N_LISTING_ERR_INVALID_INDEX, "esperando o índice da instrução, mas recebeu")
+	message.SetString(language.BrazilianPortuguese, I18N_LISTING_ERR_INVALID_LINE, "esperando o índice, linha e instrução, mas recebeu")
+
+	message.SetString(language.BrazilianPortuguese, I18N_CFG_FLAG_PROFILE, "executa o programa com a entrada e mostra quantas vezes cada aresta foi usada")
+
+	message.SetString(language.BrazilianPortuguese, I18N_FLAG_OPTIMIZE, "otimiza o programa")
+	message.SetString(language.BrazilianPortuguese, I18N_FLAG_NO_ASSERT, "ignora as asserções")
+	message.SetString(language.BrazilianPortuguese, I18N_FLAG_MAX_STEPS, "para depois de executar essa quantidade de instruções, 0 é sem limite")
+	message.SetString(language.BrazilianPortuguese, I18N_FLAG_STATS, "imprime as instruções executadas, os ciclos e o tamanho do programa")
+	message.SetString(language.BrazilianPortuguese, I18N_FLAG_COSTS, "arquivo YAML ou JSON com os ciclos de cada instrução")
+	message.SetString(language.BrazilianPortuguese, I18N_RUN_STATS, "%d passos, %d ciclos, %d instruções\n")
+	message.SetString(language.BrazilianPortuguese, I18N_ERR_COSTS_STATS, "-costs só funciona com -stats")
+	message.SetString(language.BrazilianPortuguese, I18N_FLAG_RECORD, "salva a entrada lida e a saída no arquivo de gravação")
+	message.SetString(language.BrazilianPortuguese, I18N_FLAG_DUMP_MEM, "escreve a memória, o pc e o rc em um arquivo JSON quando o programa para")
+	message.SetString(language.BrazilianPortuguese, I18N_FLAG_LOAD_MEM, "começa da memória, do pc e do rc de um snapshot JSON ou YAML")
+	message.SetString(language.BrazilianPortuguese, I18N_FLAG_ENGINE, "motor de execução, switch ou closure")
+	message.SetString(language.BrazilianPortuguese, I18N_TEST_FLAG_JOBS, "quantos programas são executados ao mesmo tempo")
+	message.SetString(language.BrazilianPortuguese, I18N_TEST_FLAG_JUNIT, "escreve um relatório JUnit XML no arquivo")
+	message.SetString(language.BrazilianPortuguese, I18N_TEST_PASS, "ok    %s (%v)\n")
+	message.SetString(language.BrazilianPortuguese, I18N_TEST_FAIL, "FALHA %s (%v)\n")
+	message.SetString(language.BrazilianPortuguese, I18N_TEST_SUMMARY, "%d passaram, %d falharam em %v\n")
+	message.SetString(language.BrazilianPortuguese, I18N_TEST_ERR_OUTPUT, "a saída é diferente da esperada")
+	message.SetString(language.BrazilianPortuguese, I18N_TEST_ERR_DIRECTIVE, "diretiva de teste inválida")
+	message.SetString(language.BrazilianPortuguese, I18N_TEST_ERR_INPUT, "a entrada deve ser números, mas recebeu")
+	message.SetString(language.BrazilianPortuguese, I18N_BENCH_FLAG_RUNS, "quantas vezes o programa é executado")
+	message.SetString(language.BrazilianPortuguese, I18N_BENCH_RUNS, "%d execuções com o motor %s em %v\n")
+	message.SetString(language.BrazilianPortuguese, I18N_BENCH_STEPS, "%d instruções por execução, %d no total\n")
+	message.SetString(language.BrazilianPortuguese, I18N_BENCH_SPEED, "%.0f instruções por segundo\n")
+	message.SetString(language.BrazilianPortuguese, I18N_BENCH_ALLOCS, "%d alocações e %d bytes por execução\n")
+	message.SetString(language.BrazilianPortuguese, I18N_ERR_ENGINE_NOT_FOUND, "o motor %s não existe\n")
+	message.SetString(language.BrazilianPortuguese, I18N_ERR_ENGINE_SWITCH, "-stats, -record, -dump-mem e -load-mem só funcionam com o motor switch")
+
+	message.SetString(language.BrazilianPortuguese, I18N_TRANSPILE_FLAG_GO, "gera um programa em Go")
+	message.SetString(language.BrazilianPortuguese, I18N_TRANSPILE_FLAG_C, "gera um programa em C99")
+	message.SetString(language.BrazilianPortuguese, I18N_TRANSPILE_FLAG_OUTPUT, "arquivo de saída")
+	message.SetString(language.BrazilianPortuguese, I18N_TRANSPILE_ERR_NO_TARGET, "escolha uma linguagem a ser gerada")
+
+	message.SetString(language.BrazilianPortuguese, I18N_CHECK_WARN_TEMPLATE, "%s: [Aviso: linha %d] %v.\n")
+	message.SetString(language.BrazilianPortuguese, I18N_CHECK_WARN_UNINITIALIZED, "$%d pode ser lido antes de qualquer escrita")
+
+	message.SetString(language.BrazilianPortuguese, I18N_LSP_HOVER_VAR, "$%d: o valor guardado na posição %d da memória")
+	message.SetString(language.BrazilianPortuguese, I18N_LSP_HOVER_REF, "&%d: o valor guardado na posição da memória cujo endereço está em $%d")
+	message.SetString(language.BrazilianPortuguese, I18N_LSP_HOVER_LABEL, "label %s: pula para a instrução %d")
+	message.SetString(language.BrazilianPortuguese, I18N_LSP_HOVER_TO, "para label [se condição]: pula para a label, quando a condição é verdadeira")
+	message.SetString(language.BrazilianPortuguese, I18N_LSP_HOVER_IF, "se: a condição do pulo, as comparações são unidas por && e ||")
+	message.SetString(language.BrazilianPortuguese, I18N_LSP_HOVER_WRITE, "escreva valor: imprime o valor")
+	message.SetString(language.BrazilianPortuguese, I18N_LSP_HOVER_READ, "leia destino [label]: guarda o próximo número da entrada no destino, ou pula para a label quando a entrada acabou")
+	message.SetString(language.BrazilianPortuguese, I18N_LSP_HOVER_ASSERT, "afirme condição [\"mensagem\"]: para o programa com um erro quando a condição é falsa")
+	message.SetString(language.BrazilianPortuguese, I18N_LSP_HOVER_SYS, "sis nome [valores]: chama uma função do hospedeiro, como rand ou time, que pode escrever nos seus argumentos")
+	message.SetString(language.BrazilianPortuguese, I18N_LSP_COMPLETION_SYS, "chamada de sistema")
+	message.SetString(language.BrazilianPortuguese, I18N_LSP_COMPLETION_LABEL, "label")
+
+	message.SetString(language.BrazilianPortuguese, I18N_TUI_TITLE, "%s  %s  %.0f passos/s  passo %d  pc %d")
+	message.SetString(language.BrazilianPortuguese, I18N_TUI_PLAYING, "executando")
+	message.SetString(language.BrazilianPortuguese, I18N_TUI_PAUSED, "pausado")
+	message.SetString(language.BrazilianPortuguese, I18N_TUI_FINISHED, "terminado")
+	message.SetString(language.BrazilianPortuguese, I18N_TUI_INPUT, "entrada (rc %d): %s")
+	message.SetString(language.BrazilianPortuguese, I18N_TUI_OUTPUT, "saída:")
+	message.SetString(language.BrazilianPortuguese, I18N_GRADE_FLAG_JSON, "escreve um relatório JSON no arquivo, - imprime ele no lugar do relatório em texto")
+	message.SetString(language.BrazilianPortuguese, I18N_GRADE_ERR_NO_CASES, "a rubrica não tem casos")
+	message.SetString(language.BrazilianPortuguese, I18N_GRADE_SUBMISSION, "%s: %g/%g\n")
+	message.SetString(language.BrazilianPortuguese, I18N_GRADE_CASE_PASS, "  ok    %s (%g/%g, %d ciclos)\n")
+	message.SetString(language.BrazilianPortuguese, I18N_GRADE_CASE_FAIL, "  FALHA %s (%g/%g, %d ciclos)\n")
+	message.SetString(language.BrazilianPortuguese, I18N_GRADE_SUBMISSION_STATS, "%s: %g/%g, %d ciclos, %d instruções\n")
+	message.SetString(language.BrazilianPortuguese, I18N_GRADE_FLAG_LEADERBOARD, "classifica as submissões por nota, ciclos e tamanho")
+	message.SetString(language.BrazilianPortuguese, I18N_GRADE_LEADERBOARD, "pos.  nota  ciclos  tamanho  arquivo\n")
+	message.SetString(language.BrazilianPortuguese, I18N_GRADE_RANK, "%4d  %4g  %6d  %7d  %s\n")
+	message.SetString(language.BrazilianPortuguese, I18N_GRADE_MISMATCH, "linha %d: esperava '%s', mas recebeu '%s'")
+	message.SetString(language.BrazilianPortuguese, I18N_GRADE_MISSING, "linha %d: esperava '%s', mas o programa parou")
+	message.SetString(language.BrazilianPortuguese, I18N_GRADE_EXTRA, "linha %d: não esperava mais nada, mas recebeu '%s'")
+	message.SetString(language.BrazilianPortuguese, I18N_RECORDING_ERR_MAGIC, "não é um arquivo de gravação")
+	message.SetString(language.BrazilianPortuguese, I18N_RECORDING_ERR_VERSION, "versão de gravação não suportada")
+	message.SetString(language.BrazilianPortuguese, I18N_REPLAY_FLAG_STOP, "para depois de executar essa quantidade de instruções e mostra o estado")
+	message.SetString(language.BrazilianPortuguese, I18N_REPLAY_ERR_PROGRAM, "a gravação é de outro programa")
+	message.SetString(language.BrazilianPortuguese, I18N_REPLAY_ERR_OUTPUT, "a reexecução é diferente da gravação")
+	message.SetString(language.BrazilianPortuguese, I18N_REPLAY_ERR_NO_SYSCALL, "a gravação não tem mais resultados de chamadas de sistema, os argumentos são")
+	message.SetString(language.BrazilianPortuguese, I18N_REPLAY_STATE, "passo %d, pc %d, rc %d\n")
+	message.SetString(language.BrazilianPortuguese, I18N_REPLAY_NEXT, "próxima: linha %d: %s\n")
+	message.SetString(language.BrazilianPortuguese, I18N_REPLAY_OK, "ok, %d passos e %d resultados como na gravação\n")
+	message.SetString(language.BrazilianPortuguese, I18N_COVER_FLAG_LCOV, "escreve a cobertura em um arquivo LCOV")
+	message.SetString(language.BrazilianPortuguese, I18N_COVER_BRANCH, "  [saltou %d, continuou %d]")
+	message.SetString(language.BrazilianPortuguese, I18N_COVER_RUN_ERROR, "%s: %v\n")
+	message.SetString(language.BrazilianPortuguese, I18N_COVER_SUMMARY, "%d execuções, instruções: %.1f%% (%d/%d), desvios: %.1f%% (%d/%d)\n")
+	message.SetString(language.BrazilianPortuguese, I18N_SNAPSHOT_ERR_VERSION, "versão de snapshot não suportada")
+	message.SetString(language.BrazilianPortuguese, I18N_SNAPSHOT_ERR_PC, "o pc está fora do programa")
+	message.SetString(language.BrazilianPortuguese, I18N_SNAPSHOT_ERR_RC, "o rc está fora da entrada")
+	message.SetString(language.BrazilianPortuguese, I18N_SNAPSHOT_ERR_PROGRAM, "o snapshot é de outro programa")
+	message.SetString(language.BrazilianPortuguese, I18N_SNAPSHOT_ERR_RECORD, "uma gravação não pode começar de um snapshot")
+	message.SetString(language.BrazilianPortuguese, I18N_DEBUG_AT, "passo %d, linha %d: %s\n")
+	message.SetString(language.BrazilianPortuguese, I18N_DEBUG_ENDED, "passo %d, o programa terminou\n")
+	message.SetString(language.BrazilianPortuguese, I18N_DEBUG_STOPPED, "passo %d, %v\n")
+	message.SetString(language.BrazilianPortuguese, I18N_DEBUG_BREAKPOINTS, "pontos de parada: %s\n")
+	message.SetString(language.BrazilianPortuguese, I18N_DEBUG_CHANGED, "$%d mudou no passo %d, linha %d: %s, de %d para %d\n")
+	message.SetString(language.BrazilianPortuguese, I18N_DEBUG_NEVER_CHANGED, "$%d nunca mudou\n")
+	message.SetString(language.BrazilianPortuguese, I18N_DEBUG_ERR_NUMBER, "esperando um número positivo, mas recebeu '%s'\n")
+	message.SetString(language.BrazilianPortuguese, I18N_DEBUG_ERR_SLOT, "esperando uma posição de memória como $0, mas recebeu '%s'\n")
+	message.SetString(language.BrazilianPortuguese, I18N_DEBUG_HELP, "s [n] passo  rs [n] passo para trás  c continua  rc continua para trás  b linha  d linha  p [$n]  last $n  o saída  q sai")
+	message.SetString(language.BrazilianPortuguese, I18N_SERVE_FLAG_ADDR, "endereço onde o servidor escuta")
+	message.SetString(language.BrazilianPortuguese, I18N_SERVE_FLAG_MAX_OUTPUT, "máximo de resultados e passos do rastro de uma execução")
+	message.SetString(language.BrazilianPortuguese, I18N_SERVE_FLAG_MAX_BODY, "tamanho máximo de uma requisição em bytes")
+	message.SetString(language.BrazilianPortuguese, I18N_SERVE_LISTENING, "escutando em http://%s\n")
+	message.SetString(language.BrazilianPortuguese, I18N_SERVE_ERR_METHOD, "a requisição deve ser um POST")
+	message.SetString(language.BrazilianPortuguese, I18N_SERVE_ERR_REQUEST, "requisição inválida")
+	message.SetString(language.BrazilianPortuguese, I18N_SERVE_ERR_BUSY, "programas demais executando, tente de novo mais tarde")
+	message.SetString(language.BrazilianPortuguese, I18N_TUI_HELP, "espaço executa/pausa  s passo  b volta  +/- velocidade  r reinicia  [/] memória  q sai")
+
 	i18n = message.NewPrinter(language.BrazilianPortuguese)
 }
 
@@ -814,10 +1619,54 @@ func print(r WriteResult) {
 
 func main() {
 	switch getUse() {
-	case USE_HELP:
-	case USE_NONE:
+	case USE_HELP, USE_NONE:
 		printUsage()
 		break
+	case USE_FMT:
+		os.Exit(runFmt(os.Args[2:]))
+		break
+	case USE_BUILD:
+		os.Exit(runBuild(os.Args[2:]))
+		break
+	case USE_DISASM:
+		os.Exit(runDisasm(os.Args[2:]))
+		break
+	case USE_CFG:
+		os.Exit(runCFG(os.Args[2:]))
+		break
+	case USE_CHECK:
+		os.Exit(runCheck(os.Args[2:]))
+		break
+	case USE_TRANSPILE:
+		os.Exit(runTranspile(os.Args[2:]))
+		break
+	case USE_BENCH:
+		os.Exit(runBench(os.Args[2:]))
+		break
+	case USE_TEST:
+		os.Exit(runTest(os.Args[2:]))
+		break
+	case USE_LSP:
+		os.Exit(runLSP(os.Args[2:]))
+		break
+	case USE_TUI:
+		os.Exit(runTUI(os.Args[2:]))
+		break
+	case USE_SERVE:
+		os.Exit(runServe(os.Args[2:]))
+		break
+	case USE_GRADE:
+		os.Exit(runGrade(os.Args[2:]))
+		break
+	case USE_REPLAY:
+		os.Exit(runReplay(os.Args[2:]))
+		break
+	case USE_DEBUG:
+		os.Exit(runDebug(os.Args[2:]))
+		break
+	case USE_COVER:
+		os.Exit(runCover(os.Args[2:]))
+		break
 	case USE_TOO_MANY_PARAMS:
 		i18n.Println(I18N_ERR_PROG_TOO_MANY_PARMS)
 		os.Exit(1)
@@ -827,18 +1676,11 @@ func main() {
 		os.Exit(1)
 		break
 	case USE_RUN:
-		input := ""
-		if len(os.Args) > 2 {
-			input = os.Args[2]
-		}
-		res, err := Run(os.Args[1], input)
-		for _, r := range res {
-			print(r)
-		}
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
+		args := os.Args[1:]
+		if strings.ToLower(args[0]) == "run" {
+			args = args[1:]
 		}
+		os.Exit(runRun(args))
 		break
 	}
 }
@@ -2,9 +2,9 @@ package main
 
 import (
 	"errors"
-	"fmt"
 	"os"
 	"path"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -24,7 +24,6 @@ func TestExamples(t *testing.T) {
 				input = ""
 			}
 
-			fmt.Println("Running", item.Name())
 			res, err := Run(path.Join(EXAMPLE_FILENAME, item.Name()), input)
 			if err != nil {
 				t.Error(err)
@@ -47,3 +46,162 @@ func TestExamples(t *testing.T) {
 		}
 	}
 }
+
+func TestKeywordsCompileToSameInstructions(t *testing.T) {
+	en := "to main\nloop:\n$0 = $0 - 1\nwrite $0\nto loop if $0 > 0\nmain:\nread $0 loop\nassert $0 > 0 \"ok\"\n"
+	pt := "#lang pt\npara main\nloop:\n$0 = $0 - 1\nescreva $0\npara loop se $0 > 0\nmain:\nleia $0 loop\nafirme $0 > 0 \"ok\"\n"
+
+	enProg, err := compile(en)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ptProg, err := compile(pt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(enProg.instructions) != len(ptProg.instructions) {
+		t.Fatalf("\nExpected: '%v'\nReceived: '%v'", len(enProg.instructions), len(ptProg.instructions))
+	}
+	for i := range enProg.instructions {
+		e, p := enProg.instructions[i], ptProg.instructions[i]
+		if e.typ != p.typ || !reflect.DeepEqual(e.val, p.val) {
+			t.Errorf("\nExpected: '%v'\nReceived: '%v'", e, p)
+		}
+	}
+	if !reflect.DeepEqual(enProg.labels, ptProg.labels) {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", enProg.labels, ptProg.labels)
+	}
+
+	if _, err := compile("#lang xx\nwrite 1"); err == nil {
+		t.Errorf("\nExpected: an error\nReceived: '%v'", err)
+	}
+}
+
+func TestAssert(t *testing.T) {
+	prog, err := compile("$0 = 2\n$1 = 0\nassert $0 == 2 \"fine\"\nassert &1 > 5 || 1 > 2\nwrite 1\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = execute(*prog, nil)
+	var aerr *AssertError
+	if !errors.As(err, &aerr) {
+		t.Fatalf("\nExpected: '%T'\nReceived: '%T'", aerr, err)
+	}
+	expected := "[Execution error: line 4] <[assert]> assertion failed: &1 > 5 || 1 > 2 (&1 -> 0 = 2)."
+	if err.Error() != expected {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", expected, err)
+	}
+
+	res, err := execute(removeAsserts(*prog), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", 1, len(res))
+	}
+
+	for _, code := range []string{"assert $0 > 1 message", "assert $0 > 1 \"a\" \"b\"", "assert \"a\"", "assert $0 > \"a"} {
+		if _, err := compile(code); err == nil {
+			t.Errorf("%q\nExpected: an error\nReceived: '%v'", code, err)
+		}
+	}
+}
+
+func TestConditionComments(t *testing.T) {
+	prog, err := compile("to end if 1 > 0 # skip the write\nwrite 1\nend:\nwrite 2 # the last one\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := execute(*prog, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 {
+		t.Fatalf("\nExpected: '%v'\nReceived: '%v'", 1, len(res))
+	}
+	if received := res[0].ToString(); received != "$ 2" {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", "$ 2", received)
+	}
+
+	incomplete := map[string]string{
+		"to end if 1 >\nend:":             "<if> expecting ending with a value, mas recbeu: >.",
+		"to end if 1 > # a comment\nend:": "<if> expecting ending with a value, mas recbeu: >.",
+		"to end if 1 > 0 &&\nend:":        "<if> expecting ending with a value, mas recbeu: &&.",
+	}
+	for code, expected := range incomplete {
+		if _, err := compile(code); err == nil || !strings.HasSuffix(err.Error(), expected) {
+			t.Errorf("%q\nExpected: '%v'\nReceived: '%v'", code, expected, err)
+		}
+	}
+}
+
+func TestLogicalConditions(t *testing.T) {
+	code := "read $0\nread $1\nto yes if $0 > 0 && $1 > 0 || $0 == 7\nwrite 0\nto end\nyes:\nwrite 1\nend:\n"
+	prog, err := compile(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[[2]int64]string{{1, 1}: "$ 1", {1, 0}: "$ 0", {0, 1}: "$ 0", {7, 0}: "$ 1"}
+	for input, expected := range cases {
+		res, err := execute(*prog, input[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != 1 {
+			t.Fatalf("%v\nExpected: '%v'\nReceived: '%v'", input, 1, len(res))
+		}
+		if received := res[0].ToString(); received != expected {
+			t.Errorf("%v\nExpected: '%v'\nReceived: '%v'", input, expected, received)
+		}
+	}
+}
+
+func TestReferenceTargets(t *testing.T) {
+	for code, expected := range map[string]string{
+		"$0 = -1\n&0 = 2":       "[Execution error: line 2] <[memory]> invalid memory access: 0.",
+		"$0 = 1024\n&0 = 2 + 1": "[Execution error: line 2] <[memory]> invalid memory access: 0.",
+		"$5 = 2000\nread &5":    "[Execution error: line 2] <[memory]> invalid memory access: 5.",
+	} {
+		prog, err := compile(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := execute(*prog, []int64{3}); err == nil || err.Error() != expected {
+			t.Errorf("%q\nExpected: '%v'\nReceived: '%v'", code, expected, err)
+		}
+	}
+
+	prog, err := compile("$0 = 10\n&0 = 4\nread &0\nwrite $10\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := execute(*prog, []int64{3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 {
+		t.Fatalf("\nExpected: '%v'\nReceived: '%v'", 1, len(res))
+	}
+	if received := res[0].ToString(); received != "$ [ 10 ] 3" {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", "$ [ 10 ] 3", received)
+	}
+}
+
+func TestErrorLines(t *testing.T) {
+	// the compilation and the execution errors count the lines from 1
+	_, err := compile("write 1\n\nfoo $0\n")
+	expected := "[Compilation error: line 3] <?> instruction not found: [foo $0]."
+	if err == nil || err.Error() != expected {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", expected, err)
+	}
+
+	prog, err := compile("write 1\n\n$0 = -1\nwrite &0\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = execute(*prog, nil)
+	expected = "[Execution error: line 4] <[memory]> invalid memory access: 0."
+	if err == nil || err.Error() != expected {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", expected, err)
+	}
+}
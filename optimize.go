@@ -0,0 +1,242 @@
+package main
+
+// The optimizer never changes what the program writes, the line of every remaining instruction is kept for errors.
+
+// isConstCondition if every value of the condition is a constant
+func isConstCondition(moveIf []interface{}) bool {
+	for i, p := range moveIf {
+		if ifInstOrder(i) == IFO_VAL && p.(InstValue).typ != VAL_CONST {
+			return false
+		}
+	}
+	return true
+}
+
+// hasRefCondition if the condition reads a reference, that could fail at execution
+func hasRefCondition(moveIf []interface{}) bool {
+	for i, p := range moveIf {
+		if ifInstOrder(i) == IFO_VAL && p.(InstValue).typ == VAL_REF {
+			return true
+		}
+	}
+	return false
+}
+
+// foldOperation compute the operation when both values are constants
+func foldOperation(op Operation) (Operation, bool) {
+	if op.op == OP_UNI || op.v1.typ != VAL_CONST || op.v2.typ != VAL_CONST {
+		return op, false
+	}
+	var res int64
+	switch op.op {
+	case OP_ADD:
+		res = op.v1.val + op.v2.val
+		break
+	case OP_SUB:
+		res = op.v1.val - op.v2.val
+		break
+	case OP_MUL:
+		res = op.v1.val * op.v2.val
+		break
+	case OP_DIV:
+		if op.v2.val == 0 {
+			return op, false
+		}
+		res = op.v1.val / op.v2.val
+		break
+	}
+	return Operation{v: op.v, v1: InstValue{typ: VAL_CONST, val: res}, op: OP_UNI}, true
+}
+
+// removeInstructions remove the instructions not kept, labels move to the next kept instruction
+func removeInstructions(prog Program, keep []bool) Program {
+	newIndex := make([]int, len(prog.instructions)+1)
+	var instructions []Instruction
+	for i, inst := range prog.instructions {
+		newIndex[i] = len(instructions)
+		if keep[i] {
+			instructions = append(instructions, inst)
+		}
+	}
+	newIndex[len(prog.instructions)] = len(instructions)
+
+	labels := make(map[string]int)
+	for name, i := range prog.labels {
+		labels[name] = newIndex[i]
+	}
+	return Program{labels: labels, instructions: instructions}
+}
+
+// removeAsserts remove every assertion, for the runs where they only cost time
+func removeAsserts(prog Program) Program {
+	keep := make([]bool, len(prog.instructions))
+	for i, inst := range prog.instructions {
+		keep[i] = inst.typ != INST_ASSERT
+	}
+	return removeInstructions(prog, keep)
+}
+
+// foldPass fold the operations on constants and the conditions that are always true or always false
+func foldPass(prog Program) (Program, bool) {
+	changed := false
+	keep := make([]bool, len(prog.instructions))
+	for i, inst := range prog.instructions {
+		keep[i] = true
+		switch inst.typ {
+		case INST_OP:
+			if op, ok := foldOperation(inst.val.(Operation)); ok {
+				prog.instructions[i].val = op
+				changed = true
+			}
+			break
+		case INST_TO:
+			moveIf := inst.val.(IfInst).moveIf
+			if moveIf == nil || !isConstCondition(moveIf) {
+				break
+			}
+			res, _ := executeIf(nil, inst)
+			if res {
+				prog.instructions[i].val = IfInst{target: inst.val.(IfInst).target}
+			} else {
+				keep[i] = false
+			}
+			changed = true
+			break
+		case INST_ASSERT:
+			// an assertion that is always false stays to fail at execution
+			cond := inst.val.(AssertInst).cond
+			if isConstCondition(cond) {
+				if res, _ := evaluateCondition(nil, inst.line, cond); res {
+					keep[i] = false
+					changed = true
+				}
+			}
+			break
+		}
+	}
+	if !changed {
+		return prog, false
+	}
+	return removeInstructions(prog, keep), true
+}
+
+// threadTarget follow the chain of unconditional jumps starting at the label
+func threadTarget(prog Program, label string) string {
+	visited := map[string]bool{label: true}
+	for {
+		i := prog.labels[label]
+		if i >= len(prog.instructions) || prog.instructions[i].typ != INST_TO {
+			return label
+		}
+		next := prog.instructions[i].val.(IfInst)
+		if next.moveIf != nil || visited[next.target] {
+			return label
+		}
+		visited[next.target] = true
+		label = next.target
+	}
+}
+
+// threadPass make every jump go straight to the end of a chain of unconditional jumps
+func threadPass(prog Program) (Program, bool) {
+	changed := false
+	for i, inst := range prog.instructions {
+		switch inst.typ {
+		case INST_TO:
+			to := inst.val.(IfInst)
+			if target := threadTarget(prog, to.target); target != to.target {
+				prog.instructions[i].val = IfInst{target: target, moveIf: to.moveIf}
+				changed = true
+			}
+			break
+		case INST_READ:
+			r := inst.val.(ReadInst)
+			if r.elseLabel == "" {
+				break
+			}
+			if target := threadTarget(prog, r.elseLabel); target != r.elseLabel {
+				prog.instructions[i].val = ReadInst{target: r.target, elseLabel: target}
+				changed = true
+			}
+			break
+		}
+	}
+	return prog, changed
+}
+
+// nextPass remove the jumps to the next instruction, when the condition can't fail
+func nextPass(prog Program) (Program, bool) {
+	changed := false
+	keep := make([]bool, len(prog.instructions))
+	for i, inst := range prog.instructions {
+		keep[i] = true
+		if inst.typ == INST_TO {
+			to := inst.val.(IfInst)
+			if prog.labels[to.target] == i+1 && !hasRefCondition(to.moveIf) {
+				keep[i] = false
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return prog, false
+	}
+	return removeInstructions(prog, keep), true
+}
+
+// reachablePass remove the instructions that can't be reached from the first one
+func reachablePass(prog Program) (Program, bool) {
+	n := len(prog.instructions)
+	keep := make([]bool, n)
+	stack := []int{0}
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if i >= n || keep[i] {
+			continue
+		}
+		keep[i] = true
+
+		inst := prog.instructions[i]
+		switch inst.typ {
+		case INST_TO:
+			to := inst.val.(IfInst)
+			stack = append(stack, prog.labels[to.target])
+			if to.moveIf != nil {
+				stack = append(stack, i+1)
+			}
+			break
+		case INST_READ:
+			if label := inst.val.(ReadInst).elseLabel; label != "" {
+				stack = append(stack, prog.labels[label])
+			}
+			stack = append(stack, i+1)
+			break
+		default:
+			stack = append(stack, i+1)
+		}
+	}
+
+	for _, k := range keep {
+		if !k {
+			return removeInstructions(prog, keep), true
+		}
+	}
+	return prog, false
+}
+
+var OPTIMIZATIONS = []func(Program) (Program, bool){foldPass, threadPass, nextPass, reachablePass}
+
+// optimize run every optimization until none of them changes the program
+func optimize(prog Program) Program {
+	prog = Program{labels: prog.labels, instructions: append([]Instruction{}, prog.instructions...)}
+	for changed := true; changed; {
+		changed = false
+		for _, pass := range OPTIMIZATIONS {
+			var c bool
+			prog, c = pass(prog)
+			changed = changed || c
+		}
+	}
+	return prog
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOptimizeExamples(t *testing.T) {
+	cases, err := findGoldenCases(EXAMPLE_FILENAME)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range cases {
+		prog, err := loadProgram(c.source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ivalues, err := caseInput(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected, steps, expectedErr := executeSteps(*prog, ivalues, 0)
+		res, optimizedSteps, err := executeSteps(optimize(*prog), ivalues, 0)
+		if !reflect.DeepEqual(expected, res) || !reflect.DeepEqual(expectedErr, err) {
+			t.Errorf("%v\nExpected: '%v' %v\nReceived: '%v' %v", c.name, expected, expectedErr, res, err)
+		}
+		if optimizedSteps > steps {
+			t.Errorf("%v\nExpected at most %d steps\nReceived: %d", c.name, steps, optimizedSteps)
+		}
+	}
+}
+
+func TestOptimizePasses(t *testing.T) {
+	code := `to main if 1 == 1
+$9 = 1
+main:
+  $0 = 2 * 3
+  to first
+first:
+  to second
+second:
+  to done if 1 > 2
+  $1 = 7 / 0
+  write $0
+done:
+  to end if $0 > 1
+end:
+  write 1`
+
+	prog, err := compile(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "$0 = 6\n$1 = 7 / 0\nwrite $0\nwrite 1\n"
+	var sb strings.Builder
+	for _, inst := range optimize(*prog).instructions {
+		sb.WriteString(instructionText(inst) + "\n")
+	}
+	if sb.String() != expected {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", expected, sb.String())
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// The recording is little endian and has the sections:
+//
+//	header  magic "FRC\x00", version uint16
+//	program sha256 of the bytecode of the executed program, 32 bytes
+//	steps   instructions executed uint64
+//	input   count uint32, then each number consumed by `read` int64
+//	output  count uint32, then each printed line (uint16 length + bytes)
+//	error   the error that stopped the program (uint16 length + bytes), empty when it ended
+//	sys     count uint32, then each syscall: count uint16, then each result (argument uint16, value int64),
+//	        then the error of the syscall (uint16 length + bytes), empty when it succeeded
+const (
+	RECORDING_MAGIC   = "FRC\x00"
+	RECORDING_VERSION = 2
+	RECORDING_EXT     = ".rec"
+)
+
+// Recording what is needed to execute the program again and the output it printed
+type Recording struct {
+	Hash   [sha256.Size]byte
+	Steps  int
+	Input  []int64
+	Output []string
+	Error  string
+	// Syscalls the results of each syscall in the order they were called, the replay doesn't call the host again
+	Syscalls []SysRecord
+}
+
+// SysRecord what one syscall wrote and the error it returned, empty when it succeeded
+type SysRecord struct {
+	Results []SysResult
+	Error   string
+}
+
+// programHash identify the program by its bytecode, so the same program built from a listing or a `.fbc` matches
+func programHash(prog Program) [sha256.Size]byte {
+	return sha256.Sum256(encodeProgram(prog))
+}
+
+// SyscallLog the syscalls of one execution
+type SyscallLog struct {
+	calls []SysRecord
+}
+
+// syscallRecorder a handler that keeps what the handler it wraps wrote, and its error
+type syscallRecorder struct {
+	Syscall
+	log *SyscallLog
+}
+
+func (r syscallRecorder) Call(call *SysCall) error {
+	err := r.Syscall.Call(call)
+	rec := SysRecord{Results: call.results}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	r.log.calls = append(r.log.calls, rec)
+	return err
+}
+
+// syscallReplayer a handler that writes the recorded results instead of calling the host,
+// a syscall after the last one recorded fails
+type syscallReplayer struct {
+	Syscall
+	log *SyscallLog
+}
+
+func (r syscallReplayer) Call(call *SysCall) error {
+	if len(r.log.calls) == 0 {
+		return formatError("[sys]", I18N_REPLAY_ERR_NO_SYSCALL, call.Args)
+	}
+	rec := r.log.calls[0]
+	r.log.calls = r.log.calls[1:]
+	for _, res := range rec.Results {
+		if err := call.Set(res.Arg, res.Value); err != nil {
+			return err
+		}
+	}
+	if rec.Error != "" {
+		return errors.New(rec.Error)
+	}
+	return nil
+}
+
+// recordSyscalls keep the results of every syscall executed by the VM
+func recordSyscalls(vm *VM) *SyscallLog {
+	log := &SyscallLog{}
+	for name, s := range vm.syscalls {
+		vm.registerSyscall(name, syscallRecorder{Syscall: s, log: log})
+	}
+	return log
+}
+
+// replaySyscalls make the syscalls executed by the VM return the recorded results
+func replaySyscalls(vm *VM, calls []SysRecord) {
+	log := &SyscallLog{calls: calls}
+	for name, s := range vm.syscalls {
+		vm.registerSyscall(name, syscallReplayer{Syscall: s, log: log})
+	}
+}
+
+// newRecording the execution of the VM, only the input that was read is kept
+func newRecording(vm *VM, syscalls *SyscallLog, err error) Recording {
+	rec := Recording{Hash: programHash(vm.prog), Steps: vm.steps, Input: append([]int64{}, vm.input[:vm.rc]...)}
+	if syscalls != nil {
+		rec.Syscalls = syscalls.calls
+	}
+	for _, r := range vm.results {
+		rec.Output = append(rec.Output, r.ToString())
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	return rec
+}
+
+func writeRecordingString(w *bytecodeWriter, text string) {
+	w.write(uint16(len(text)))
+	w.buf.WriteString(text)
+}
+
+// encodeRecording serialize the recording in the format described above
+func encodeRecording(rec Recording) []byte {
+	w := &bytecodeWriter{}
+	w.buf.WriteString(RECORDING_MAGIC)
+	w.write(uint16(RECORDING_VERSION))
+	w.write(rec.Hash)
+	w.write(uint64(rec.Steps))
+	w.write(uint32(len(rec.Input)))
+	w.write(rec.Input)
+	w.write(uint32(len(rec.Output)))
+	for _, line := range rec.Output {
+		writeRecordingString(w, line)
+	}
+	writeRecordingString(w, rec.Error)
+	w.write(uint32(len(rec.Syscalls)))
+	for _, call := range rec.Syscalls {
+		w.write(uint16(len(call.Results)))
+		for _, res := range call.Results {
+			w.write(uint16(res.Arg))
+			w.write(res.Value)
+		}
+		writeRecordingString(w, call.Error)
+	}
+	return w.buf.Bytes()
+}
+
+// decodeRecording load and validate a recording serialized by `encodeRecording`
+func decodeRecording(data []byte) (*Recording, error) {
+	if !bytes.HasPrefix(data, []byte(RECORDING_MAGIC)) {
+		return nil, formatError("recording", I18N_RECORDING_ERR_MAGIC, RECORDING_EXT)
+	}
+	r := &bytecodeReader{r: bytes.NewReader(data[len(RECORDING_MAGIC):])}
+
+	var version uint16
+	r.read(&version)
+	if r.err == nil && version != RECORDING_VERSION {
+		return nil, formatError("recording", I18N_RECORDING_ERR_VERSION, version)
+	}
+
+	rec := &Recording{}
+	r.read(&rec.Hash)
+	var steps uint64
+	r.read(&steps)
+	rec.Steps = int(steps)
+
+	// each number has 8 bytes, `readCount` only knows that it has at least one
+	n := r.readCount()
+	if r.err == nil && n*8 > r.r.Len() {
+		r.invalid(n)
+	}
+	if r.err == nil {
+		rec.Input = make([]int64, n)
+		r.read(rec.Input)
+	}
+
+	n = r.readCount()
+	for i := 0; i < n && r.err == nil; i++ {
+		rec.Output = append(rec.Output, r.readString())
+	}
+	rec.Error = r.readString()
+
+	n = r.readCount()
+	for i := 0; i < n && r.err == nil; i++ {
+		var count uint16
+		r.read(&count)
+		var call SysRecord
+		for j := 0; j < int(count) && r.err == nil; j++ {
+			var arg uint16
+			var value int64
+			r.read(&arg)
+			r.read(&value)
+			call.Results = append(call.Results, SysResult{Arg: int(arg), Value: value})
+		}
+		call.Error = r.readString()
+		rec.Syscalls = append(rec.Syscalls, call)
+	}
+
+	if r.err == nil && r.r.Len() > 0 {
+		r.invalid(r.r.Len())
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return rec, nil
+}
+
+func saveRecording(path string, rec Recording) error {
+	return os.WriteFile(path, encodeRecording(rec), 0644)
+}
+
+func loadRecording(path string) (*Recording, error) {
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := decodeRecording(dat)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return rec, nil
+}
+
+// checkRecording the recording must be of the same program, after the optimizations
+func checkRecording(prog Program, rec Recording) error {
+	if programHash(prog) != rec.Hash {
+		return formatError("replay", I18N_REPLAY_ERR_PROGRAM, hex.EncodeToString(rec.Hash[:8]))
+	}
+	return nil
+}
+
+// replay execute the program again with the recorded input, stopping after `stop` steps when it isn't negative,
+// the recorded steps are the limit so a different execution can't run forever
+func replay(prog Program, rec Recording, stop int) (*VM, error) {
+	vm := newVM(prog, rec.Input)
+	vm.maxSteps = rec.Steps
+	replaySyscalls(vm, rec.Syscalls)
+	for !vm.done() && (stop < 0 || vm.steps < stop) {
+		if err := vm.step(); err != nil {
+			return vm, err
+		}
+	}
+	return vm, nil
+}
+
+// replayMismatch the first line different from the recording, the error is the last line of the output,
+// a replay that was stopped only has to be the beginning of the recording
+func replayMismatch(rec Recording, vm *VM, err error, stopped bool) *Mismatch {
+	expected := rec.Output
+	if rec.Error != "" {
+		expected = append(append([]string{}, expected...), rec.Error)
+	}
+	var received []string
+	for _, r := range vm.results {
+		received = append(received, r.ToString())
+	}
+	if err != nil {
+		received = append(received, err.Error())
+	}
+	if stopped && err == nil && len(received) <= len(expected) {
+		expected = expected[:len(received)]
+	}
+	return firstMismatch(expected, received)
+}
+
+// printReplayState the state of a replay that was stopped, only the memory slots that aren't zero are shown
+func printReplayState(vm *VM) {
+	i18n.Printf(I18N_REPLAY_STATE, vm.steps, vm.pc, vm.rc)
+	if !vm.done() {
+		inst := vm.prog.instructions[vm.pc]
+		i18n.Printf(I18N_REPLAY_NEXT, inst.line, instructionText(inst))
+	}
+	for i, v := range vm.mem {
+		if v != 0 {
+			fmt.Printf("$%d = %d\n", i, v)
+		}
+	}
+	for _, r := range vm.results {
+		print(r)
+	}
+}
+
+// runReplay the `replay` command, check that the program prints again what was recorded
+func runReplay(args []string) int {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	optimized := flags.Bool("O", false, i18n.Sprintf(I18N_FLAG_OPTIMIZE))
+	noAssert := flags.Bool("no-assert", false, i18n.Sprintf(I18N_FLAG_NO_ASSERT))
+	stop := flags.Int("stop", -1, i18n.Sprintf(I18N_REPLAY_FLAG_STOP))
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		printUsage()
+		return 1
+	}
+	if !strings.HasSuffix(flags.Arg(0), RECORDING_EXT) || !isProgramFile(flags.Arg(1)) {
+		i18n.Println(I18N_ERR_PROG_NEED_ASM_EXT)
+		return 1
+	}
+
+	rec, err := loadRecording(flags.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	prog, err := loadProgram(flags.Arg(1))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if *noAssert {
+		*prog = removeAsserts(*prog)
+	}
+	if *optimized {
+		*prog = optimize(*prog)
+	}
+
+	if err := checkRecording(*prog, *rec); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	vm, execErr := replay(*prog, *rec, *stop)
+	stopped := *stop >= 0 && vm.steps < rec.Steps
+	if stopped {
+		printReplayState(vm)
+	}
+	if m := replayMismatch(*rec, vm, execErr, stopped); m != nil {
+		i18n.Println(I18N_REPLAY_ERR_OUTPUT)
+		fmt.Println(mismatchText(m))
+		return 1
+	}
+	if !stopped {
+		i18n.Printf(I18N_REPLAY_OK, vm.steps, len(vm.results))
+	}
+	return 0
+}
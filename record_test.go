@@ -0,0 +1,161 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func recordProgram(t *testing.T, code string, input []int64, maxSteps int) (Program, Recording) {
+	prog, err := compile(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm := newVM(*prog, input)
+	vm.maxSteps = maxSteps
+	syscalls := recordSyscalls(vm)
+	err = vm.run()
+	return *prog, newRecording(vm, syscalls, err)
+}
+
+func TestRecording(t *testing.T) {
+	code := "read $0\nloop:\n$1 = $0 * 2\nwrite $1\nread $0 end\nto loop\nend:\n"
+	prog, rec := recordProgram(t, code, []int64{3, -4, 5}, 0)
+	expected := Recording{
+		Hash:   programHash(prog),
+		Steps:  12,
+		Input:  []int64{3, -4, 5},
+		Output: []string{"$ [ 1 ] 6", "$ [ 1 ] -8", "$ [ 1 ] 10"},
+	}
+	if !reflect.DeepEqual(rec, expected) {
+		t.Fatalf("\nExpected: %+v\nReceived: %+v", expected, rec)
+	}
+
+	data := encodeRecording(rec)
+	decoded, err := decodeRecording(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*decoded, rec) {
+		t.Errorf("\nExpected: %+v\nReceived: %+v", rec, *decoded)
+	}
+	for _, invalid := range [][]byte{data[:len(data)-1], append(append([]byte{}, data...), 0), []byte("FBC\x00")} {
+		if _, err := decodeRecording(invalid); err == nil {
+			t.Errorf("%q\nExpected: an error\nReceived: '%v'", invalid, err)
+		}
+	}
+
+	vm, err := replay(prog, rec, -1)
+	if m := replayMismatch(rec, vm, err, false); m != nil {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", nil, m)
+	}
+	if vm.steps != rec.Steps {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", rec.Steps, vm.steps)
+	}
+
+	vm, err = replay(prog, rec, 4)
+	if m := replayMismatch(rec, vm, err, true); m != nil {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", nil, m)
+	}
+	if vm.steps != 4 {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", 4, vm.steps)
+	}
+	if vm.pc != 4 {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", 4, vm.pc)
+	}
+	if vm.mem[1] != 6 {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", 6, vm.mem[1])
+	}
+
+	changed := rec
+	changed.Output = []string{"$ [ 1 ] 6", "$ [ 1 ] 8", "$ [ 1 ] 10"}
+	vm, err = replay(prog, changed, -1)
+	mismatch := Mismatch{Index: 2, Expected: "$ [ 1 ] 8", Received: "$ [ 1 ] -8"}
+	if m := replayMismatch(changed, vm, err, false); m == nil || *m != mismatch {
+		t.Errorf("\nExpected: %+v\nReceived: %+v", mismatch, m)
+	}
+
+	other, err := compile("write 1\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkRecording(*other, rec); err == nil {
+		t.Errorf("\nExpected: an error\nReceived: '%v'", err)
+	}
+}
+
+func TestRecordingError(t *testing.T) {
+	prog, rec := recordProgram(t, "loop:\nwrite 1\nto loop\n", nil, 5)
+	if rec.Steps != 5 {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", 5, rec.Steps)
+	}
+	if len(rec.Output) != 3 {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", 3, len(rec.Output))
+	}
+	if limit := stepLimitError(3, 5).Error(); rec.Error != limit {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", limit, rec.Error)
+	}
+	vm, err := replay(prog, rec, -1)
+	if m := replayMismatch(rec, vm, err, false); m != nil {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", nil, m)
+	}
+}
+
+func TestRecordingSyscalls(t *testing.T) {
+	prog, rec := recordProgram(t, "sys rand $0 1 1000000\nwrite $0\nsys time $1\nsys rand $2 1 1000000\nwrite $2\n", nil, 0)
+	if len(rec.Syscalls) != 3 {
+		t.Fatalf("\nExpected: '%v'\nReceived: '%v'", 3, len(rec.Syscalls))
+	}
+	if len(rec.Syscalls[1].Results) != 1 {
+		t.Fatalf("\nExpected: '%v'\nReceived: '%v'", 1, len(rec.Syscalls[1].Results))
+	}
+	decoded, err := decodeRecording(encodeRecording(rec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*decoded, rec) {
+		t.Errorf("\nExpected: %+v\nReceived: %+v", rec, *decoded)
+	}
+
+	// the random numbers and the time are the recorded ones
+	for i := 0; i < 3; i++ {
+		vm, err := replay(prog, *decoded, -1)
+		if m := replayMismatch(rec, vm, err, false); m != nil {
+			t.Fatalf("\nExpected: '%v'\nReceived: '%v'", nil, m)
+		}
+		if vm.mem[1] != rec.Syscalls[1].Results[0].Value {
+			t.Errorf("\nExpected: '%v'\nReceived: '%v'", rec.Syscalls[1].Results[0].Value, vm.mem[1])
+		}
+	}
+
+	// a recording without the last syscall can't call the host for it
+	missing := rec
+	missing.Syscalls = rec.Syscalls[:2]
+	vm, err := replay(prog, missing, -1)
+	if err == nil || !strings.Contains(err.Error(), I18N_REPLAY_ERR_NO_SYSCALL) {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", I18N_REPLAY_ERR_NO_SYSCALL, err)
+	}
+	if vm.mem[2] != 0 {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", 0, vm.mem[2])
+	}
+}
+
+func TestRecordingSyscallError(t *testing.T) {
+	prog, rec := recordProgram(t, "sys rand $0 5 1\nwrite $0\n", nil, 0)
+	if len(rec.Syscalls) != 1 || rec.Syscalls[0].Error == "" {
+		t.Fatalf("\nExpected: an error\nReceived: %+v", rec.Syscalls)
+	}
+	decoded, err := decodeRecording(encodeRecording(rec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*decoded, rec) {
+		t.Errorf("\nExpected: %+v\nReceived: %+v", rec, *decoded)
+	}
+
+	// the replay fails with the recorded error instead of running out of syscalls
+	_, err = replay(prog, *decoded, -1)
+	if err == nil || err.Error() != rec.Error {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", rec.Error, err)
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"runtime"
+	"time"
+)
+
+const (
+	SERVE_ADDR       = "localhost:8080"
+	SERVE_MAX_STEPS  = 1000000
+	SERVE_MAX_OUTPUT = 10000
+	SERVE_MAX_BODY   = 64 * 1024
+	SERVE_QUEUE_WAIT = 5 * time.Second
+)
+
+// PlaygroundRequest the body of `POST /api/run`
+type PlaygroundRequest struct {
+	Source   string  `json:"source"`
+	Input    []int64 `json:"input"`
+	Trace    bool    `json:"trace"`
+	Optimize bool    `json:"optimize"`
+}
+
+// TraceStep one instruction executed, in the order of the execution
+type TraceStep struct {
+	Step        int    `json:"step"`
+	PC          int    `json:"pc"`
+	Line        int    `json:"line"`
+	Instruction string `json:"instruction"`
+}
+
+// PlaygroundResponse the printed results and the error, like `fasm run` shows them
+type PlaygroundResponse struct {
+	Results []string    `json:"results"`
+	Error   string      `json:"error,omitempty"`
+	Steps   int         `json:"steps"`
+	Trace   []TraceStep `json:"trace,omitempty"`
+}
+
+// Sandbox the limits of every run, at most `jobs` programs are executed at the same time
+type Sandbox struct {
+	maxSteps  int
+	maxOutput int
+	maxBody   int64
+	wait      time.Duration
+	slots     chan struct{}
+}
+
+func newSandbox(jobs int, maxSteps int, maxOutput int, maxBody int64) *Sandbox {
+	if jobs < 1 {
+		jobs = 1
+	}
+	return &Sandbox{maxSteps: maxSteps, maxOutput: maxOutput, maxBody: maxBody, wait: SERVE_QUEUE_WAIT, slots: make(chan struct{}, jobs)}
+}
+
+func outputLimitError(line int, maxOutput int) error {
+	return executionError(line, formatError("[limit]", I18N_EXEC_ERR_OUTPUT_LIMIT, maxOutput))
+}
+
+// run execute the program within the limits, the trace and the results count for the output limit
+func (s *Sandbox) run(prog Program, input []int64, trace bool) PlaygroundResponse {
+	res := PlaygroundResponse{Results: []string{}}
+	vm := newVM(prog, input)
+	vm.maxSteps = s.maxSteps
+
+	var err error
+	for !vm.done() {
+		inst := prog.instructions[vm.pc]
+		if trace {
+			if len(res.Trace)+len(vm.results) >= s.maxOutput {
+				err = outputLimitError(inst.line, s.maxOutput)
+				break
+			}
+			res.Trace = append(res.Trace, TraceStep{Step: vm.steps + 1, PC: vm.pc, Line: inst.line, Instruction: instructionText(inst)})
+		}
+		if err = vm.step(); err != nil {
+			break
+		}
+		if len(res.Trace)+len(vm.results) > s.maxOutput {
+			vm.results = vm.results[:len(vm.results)-1]
+			err = outputLimitError(inst.line, s.maxOutput)
+			break
+		}
+	}
+
+	for _, r := range vm.results {
+		res.Results = append(res.Results, r.ToString())
+	}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	res.Steps = vm.steps
+	return res
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// handleRun the JSON API, the request waits for a free slot until the sandbox gives up
+func (s *Sandbox) handleRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSON(w, http.StatusMethodNotAllowed, PlaygroundResponse{Error: i18n.Sprintf(I18N_SERVE_ERR_METHOD)})
+		return
+	}
+
+	var req PlaygroundRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.maxBody))
+	if err := dec.Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, PlaygroundResponse{Error: fmt.Sprintf("%s: %v", i18n.Sprintf(I18N_SERVE_ERR_REQUEST), err)})
+		return
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-time.After(s.wait):
+		writeJSON(w, http.StatusServiceUnavailable, PlaygroundResponse{Error: i18n.Sprintf(I18N_SERVE_ERR_BUSY)})
+		return
+	case <-r.Context().Done():
+		return
+	}
+
+	prog, err := compile(req.Source)
+	if err != nil {
+		writeJSON(w, http.StatusOK, PlaygroundResponse{Results: []string{}, Error: err.Error()})
+		return
+	}
+	if req.Optimize {
+		*prog = optimize(*prog)
+	}
+	writeJSON(w, http.StatusOK, s.run(*prog, req.Input, req.Trace))
+}
+
+func (s *Sandbox) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/run", s.handleRun)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, PLAYGROUND_PAGE)
+	})
+	return mux
+}
+
+// runServe the `serve` command, a playground for the local network
+func runServe(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", SERVE_ADDR, i18n.Sprintf(I18N_SERVE_FLAG_ADDR))
+	jobs := flags.Int("j", runtime.NumCPU(), i18n.Sprintf(I18N_TEST_FLAG_JOBS))
+	maxSteps := flags.Int("max-steps", SERVE_MAX_STEPS, i18n.Sprintf(I18N_FLAG_MAX_STEPS))
+	maxOutput := flags.Int("max-output", SERVE_MAX_OUTPUT, i18n.Sprintf(I18N_SERVE_FLAG_MAX_OUTPUT))
+	maxBody := flags.Int64("max-body", SERVE_MAX_BODY, i18n.Sprintf(I18N_SERVE_FLAG_MAX_BODY))
+	flags.Parse(args)
+
+	if *maxSteps <= 0 {
+		*maxSteps = SERVE_MAX_STEPS
+	}
+	sandbox := newSandbox(*jobs, *maxSteps, *maxOutput, *maxBody)
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           sandbox.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	i18n.Printf(I18N_SERVE_LISTENING, *addr)
+	if err := server.ListenAndServe(); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
+
+const PLAYGROUND_PAGE = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>fasm playground</title>
+<style>
+	body { font-family: sans-serif; margin: 2em; max-width: 60em; }
+	textarea, pre { font-family: monospace; width: 100%; box-sizing: border-box; }
+	pre { background: #f4f4f4; padding: 1em; min-height: 4em; }
+	.error { color: #b00; }
+</style>
+</head>
+<body>
+<h1>fasm playground</h1>
+<textarea id="source" rows="16">read $0
+$1 = $0 * 2
+write $1
+</textarea>
+<p>
+	<label>input <input id="input" value="21" size="40"></label>
+	<label><input id="trace" type="checkbox"> trace</label>
+	<label><input id="optimize" type="checkbox"> -O</label>
+	<button id="run">run</button>
+</p>
+<pre id="output"></pre>
+<pre id="trace-output" hidden></pre>
+<script>
+document.getElementById("run").onclick = async () => {
+	const input = document.getElementById("input").value.split(/[\s,]+/).filter(v => v !== "").map(Number);
+	const body = {
+		source: document.getElementById("source").value,
+		input: input,
+		trace: document.getElementById("trace").checked,
+		optimize: document.getElementById("optimize").checked,
+	};
+	const output = document.getElementById("output");
+	const trace = document.getElementById("trace-output");
+	output.textContent = "...";
+	const res = await (await fetch("/api/run", { method: "POST", body: JSON.stringify(body) })).json();
+	output.textContent = (res.results || []).join("\n");
+	if (res.error) {
+		const err = document.createElement("span");
+		err.className = "error";
+		err.textContent = (output.textContent ? "\n" : "") + res.error;
+		output.appendChild(err);
+	}
+	trace.hidden = !res.trace;
+	trace.textContent = (res.trace || []).map(t => t.step + "\t" + t.line + "\t" + t.instruction).join("\n");
+};
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func postRun(t *testing.T, s *Sandbox, body string) (int, PlaygroundResponse) {
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/run", strings.NewReader(body)))
+	var res PlaygroundResponse
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, res
+}
+
+func TestServeRun(t *testing.T) {
+	s := newSandbox(2, 1000, 10, 1024)
+
+	cases := []struct {
+		body     string
+		status   int
+		expected PlaygroundResponse
+	}{
+		{
+			`{"source": "read $0\n$1 = $0 * 2\nwrite $1", "input": [21]}`,
+			http.StatusOK,
+			PlaygroundResponse{Results: []string{"$ [ 1 ] 42"}, Steps: 3},
+		},
+		{
+			`{"source": "write 1\nwrite", "input": []}`,
+			http.StatusOK,
+			PlaygroundResponse{Results: []string{}, Error: "[Compilation error: line 2] <write> missing a parameter after: [write]."},
+		},
+		{
+			`{"source": "loop:\nto loop"}`,
+			http.StatusOK,
+			PlaygroundResponse{Results: []string{}, Error: "[Execution error: line 2] <[limit]> too many instructions executed, the limit is: 1000.", Steps: 1000},
+		},
+		{
+			`{"source": "write 7\nread $0", "trace": true}`,
+			http.StatusOK,
+			PlaygroundResponse{
+				Results: []string{"$ 7"},
+				Error:   "[Execution error: line 2] <[read]> trying to read when there is no more input: no else label.",
+				Steps:   2,
+				Trace:   []TraceStep{{1, 0, 1, "write 7"}, {2, 1, 2, "read $0"}},
+			},
+		},
+		{
+			`{"source": "loop:\nwrite 1\nto loop"}`,
+			http.StatusOK,
+			PlaygroundResponse{
+				Results: strings.Split(strings.Repeat("$ 1,", 10), ",")[:10],
+				Error:   "[Execution error: line 2] <[limit]> too many results, the limit is: 10.",
+				Steps:   21,
+			},
+		},
+		{`{"source": ` + strings.Repeat(" ", 2000) + `"write 1"}`, http.StatusBadRequest, PlaygroundResponse{}},
+	}
+
+	for _, c := range cases {
+		status, res := postRun(t, s, c.body)
+		if status != c.status {
+			t.Errorf("%.40s\nExpected: %d\nReceived: %d", c.body, c.status, status)
+			continue
+		}
+		if status == http.StatusOK && !reflect.DeepEqual(res, c.expected) {
+			t.Errorf("%.40s\nExpected: %+v\nReceived: %+v", c.body, c.expected, res)
+		}
+	}
+}
+
+func TestServeBusy(t *testing.T) {
+	s := newSandbox(1, 1000, 10, 1024)
+	s.wait = 10 * time.Millisecond
+	s.slots <- struct{}{}
+
+	if status, res := postRun(t, s, `{"source": "write 1"}`); status != http.StatusServiceUnavailable || res.Error == "" {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", http.StatusServiceUnavailable, fmt.Sprintf("%d %+v", status, res))
+	}
+	<-s.slots
+	if status, _ := postRun(t, s, `{"source": "write 1"}`); status != http.StatusOK {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", http.StatusOK, status)
+	}
+
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "/api/run") {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", http.StatusOK, rec.Code)
+	}
+}
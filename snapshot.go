@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+)
+
+// SNAPSHOT_VERSION the version written by `--dump-mem`, a snapshot of another version is refused
+const SNAPSHOT_VERSION = 1
+
+// Snapshot the state of the VM, the memory only has the slots that aren't zero.
+// The VM has no call stack, the `pc` and the `rc` are the whole control state.
+type Snapshot struct {
+	Version int `json:"version" yaml:"version"`
+	// Program the sha256 of the bytecode of the program that was dumped, like the hash of a recording
+	Program string          `json:"program,omitempty" yaml:"program,omitempty"`
+	PC      int             `json:"pc" yaml:"pc"`
+	RC      int             `json:"rc" yaml:"rc"`
+	Mem     map[int64]int64 `json:"mem" yaml:"mem"`
+	// Steps and Error how the execution that was dumped stopped, they are ignored by `--load-mem`
+	Steps int    `json:"steps,omitempty" yaml:"steps,omitempty"`
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// newSnapshot the state of the VM after it stopped with the error, nil when it ended
+func newSnapshot(vm *VM, err error) Snapshot {
+	hash := programHash(vm.prog)
+	snap := Snapshot{Version: SNAPSHOT_VERSION, Program: hex.EncodeToString(hash[:]), PC: vm.pc, RC: vm.rc, Mem: map[int64]int64{}, Steps: vm.steps}
+	for i, v := range vm.mem {
+		if v != 0 {
+			snap.Mem[int64(i)] = v
+		}
+	}
+	if err != nil {
+		snap.Error = err.Error()
+	}
+	return snap
+}
+
+// restore start the VM from the snapshot, the `pc` can be the end of the program and the `rc` the end of the input.
+// The snapshot must be of the same program, after the optimizations, only one without a program can start at the `pc` 0.
+func (vm *VM) restore(snap Snapshot) error {
+	if snap.Version != SNAPSHOT_VERSION {
+		return formatError("snapshot", I18N_SNAPSHOT_ERR_VERSION, snap.Version)
+	}
+	hash := programHash(vm.prog)
+	if (snap.Program == "" && snap.PC != 0) || (snap.Program != "" && snap.Program != hex.EncodeToString(hash[:])) {
+		return formatError("snapshot", I18N_SNAPSHOT_ERR_PROGRAM, snap.Program)
+	}
+	if snap.PC < 0 || snap.PC > len(vm.prog.instructions) {
+		return formatError("snapshot", I18N_SNAPSHOT_ERR_PC, snap.PC)
+	}
+	if snap.RC < 0 || snap.RC > len(vm.input) {
+		return formatError("snapshot", I18N_SNAPSHOT_ERR_RC, snap.RC)
+	}
+	for slot := range snap.Mem {
+		if slot < 0 || slot >= MEMORY_SIZE {
+			return formatError("snapshot", I18N_EXEC_ERR_INVALID_MEMORY_ACCESS, slot)
+		}
+	}
+	for slot, v := range snap.Mem {
+		vm.mem[slot] = v
+	}
+	vm.pc = snap.PC
+	vm.rc = snap.RC
+	return nil
+}
+
+func saveSnapshot(path string, snap Snapshot) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(snap); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// loadSnapshot a snapshot written by `saveSnapshot`, or by hand in JSON or YAML
+func loadSnapshot(path string) (*Snapshot, error) {
+	var snap Snapshot
+	if err := decodeConfig(path, &snap); err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	prog, err := compile("read $0\nloop:\n$1 = $0 * 2\nwrite $1\nread $0 end\nto loop\nend:\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := []int64{3, 4, 5}
+	vm := newVM(*prog, input)
+	vm.maxSteps = 6
+	runErr := vm.run()
+	if runErr == nil {
+		t.Fatalf("\nExpected: an error\nReceived: '%v'", runErr)
+	}
+
+	path := filepath.Join(t.TempDir(), "mem.json")
+	if err := saveSnapshot(path, newSnapshot(vm, runErr)); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := loadSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := programHash(*prog)
+	expected := Snapshot{Version: SNAPSHOT_VERSION, Program: hex.EncodeToString(hash[:]), PC: 2, RC: 2, Mem: map[int64]int64{0: 4, 1: 8}, Steps: 6, Error: runErr.Error()}
+	if !reflect.DeepEqual(*snap, expected) {
+		t.Errorf("\nExpected: %+v\nReceived: %+v", expected, *snap)
+	}
+
+	// the rest of the execution is the same of an execution without the limit
+	resumed := newVM(*prog, input)
+	if err := resumed.restore(*snap); err != nil {
+		t.Fatal(err)
+	}
+	if err := resumed.run(); err != nil {
+		t.Fatal(err)
+	}
+	results, _ := execute(*prog, input)
+	if received := append(vm.results, resumed.results...); !reflect.DeepEqual(received, results) {
+		t.Errorf("\nExpected: %v\nReceived: %v", results, received)
+	}
+
+	yamlPath := filepath.Join(t.TempDir(), "mem.yaml")
+	if err := os.WriteFile(yamlPath, []byte("version: 1\nmem: {5: 9}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if snap, err = loadSnapshot(yamlPath); err != nil {
+		t.Fatal(err)
+	}
+	if mem := map[int64]int64{5: 9}; !reflect.DeepEqual(snap.Mem, mem) {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", mem, snap.Mem)
+	}
+
+	invalid := []Snapshot{
+		{Version: 2},
+		{Version: SNAPSHOT_VERSION, PC: len(prog.instructions) + 1},
+		{Version: SNAPSHOT_VERSION, RC: 4},
+		{Version: SNAPSHOT_VERSION, Mem: map[int64]int64{MEMORY_SIZE: 1}},
+		{Version: SNAPSHOT_VERSION, PC: 2},
+		{Version: SNAPSHOT_VERSION, Program: "00", PC: 2},
+	}
+	for _, s := range invalid {
+		if err := newVM(*prog, input).restore(s); err == nil {
+			t.Errorf("%+v\nExpected: an error\nReceived: '%v'", s, err)
+		}
+	}
+
+	// the pc of the snapshot would be valid in another program with as many instructions
+	other, err := compile("read $0\nloop:\n$1 = $0 + $0\nwrite $1\nread $0 end\nto loop\nend:\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := newVM(*other, input).restore(newSnapshot(vm, nil)); err == nil {
+		t.Errorf("\nExpected: an error\nReceived: '%v'", err)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Syscall a capability of the host, like random numbers or the time, that a program calls with `sys name args...`
+type Syscall interface {
+	// Arity how many arguments the call needs, negative is any amount, a call with another amount doesn't compile
+	Arity() int
+	// Call the arguments are already read from memory, the results are written with `SysCall.Set`
+	Call(call *SysCall) error
+}
+
+// SYSCALLS the syscalls known by `compile`, each VM starts with a copy of them
+var SYSCALLS = map[string]Syscall{
+	"rand": randSyscall{},
+	"time": timeSyscall{},
+}
+
+// registerSyscall make `sys name` compile and execute the handler, a syscall with the same name is replaced.
+// It isn't safe for concurrent use, it must be called at init time before any program is compiled or executed.
+func registerSyscall(name string, s Syscall) {
+	SYSCALLS[name] = s
+}
+
+// registerInstruction add an instruction after the built-in ones, a new syntax usually
+// compiles into a syscall with `newSysInst`.
+// It isn't safe for concurrent use, it must be called at init time before any program is compiled.
+func registerInstruction(f InstFunc) {
+	INSTRUCTIONS = append(INSTRUCTIONS, f)
+}
+
+// registerSyscall replace the handler of a syscall only for this VM, the programs still compile with `SYSCALLS`
+func (vm *VM) registerSyscall(name string, s Syscall) {
+	vm.syscalls[name] = s
+}
+
+// SysInst a call of a syscall, the arguments can be constants, variables or references
+type SysInst struct {
+	name string
+	args []InstValue
+}
+
+// newSysInst the instruction that calls a registered syscall with the right amount of arguments
+func newSysInst(name string, args []InstValue) (*Instruction, error) {
+	s, ok := SYSCALLS[name]
+	if !ok {
+		return nil, formatError("sys", I18N_ERR_SYS_NOT_FOUND, name)
+	}
+	if s.Arity() >= 0 && s.Arity() != len(args) {
+		return nil, formatError("sys", I18N_ERR_SYS_ARITY, s.Arity())
+	}
+	return &Instruction{typ: INST_SYS, val: SysInst{name: name, args: args}}, nil
+}
+
+// hasSysInst will follow the pattern `sys name value*`
+func hasSysInst(kw Keywords, tokens []string) (*Instruction, error) {
+	if tokens[0] != kw.sys {
+		return nil, nil
+	}
+	if len(tokens) < 2 {
+		return nil, formatError("sys", I18N_ERR_MISSING_PARAM, tokens)
+	}
+	if !isWord(tokens[1]) {
+		return nil, formatError("sys", I18N_ERR_SYS_INVALID_WORD, tokens[1])
+	}
+
+	var args []InstValue
+	for _, token := range tokens[2:] {
+		if isCommentInst([]string{token}) {
+			break
+		}
+		v := hasValue(token)
+		if v == nil {
+			return nil, formatError("sys", I18N_ERR_READ_EXPECT_VALUE, token)
+		}
+		args = append(args, *v)
+	}
+	return newSysInst(tokens[1], args)
+}
+
+// SysCall one call of a syscall, `Args` has the values of the arguments
+type SysCall struct {
+	Args []int64
+	// slots the memory slot of each argument, -1 for a constant
+	slots []int64
+	mem   []int64
+	// results what was written with `Set`, in order
+	results []SysResult
+}
+
+// SysResult a value written by a syscall to the argument `Arg`
+type SysResult struct {
+	Arg   int
+	Value int64
+}
+
+// Set write the result to the slot of the argument `i`, a constant argument can't be written
+func (c *SysCall) Set(i int, v int64) error {
+	if i < 0 || i >= len(c.slots) || c.slots[i] < 0 {
+		return formatError("[sys]", I18N_EXEC_ERR_SYS_SET, i)
+	}
+	c.mem[c.slots[i]] = v
+	c.Args[i] = v
+	c.results = append(c.results, SysResult{Arg: i, Value: v})
+	return nil
+}
+
+// syscall read the arguments and call the handler of the instruction pointed by `pc`
+func (vm *VM) syscall(inst Instruction) error {
+	s := inst.val.(SysInst)
+	call := &SysCall{Args: make([]int64, len(s.args)), slots: make([]int64, len(s.args)), mem: vm.mem}
+	for i, arg := range s.args {
+		v, err := valueFromMem(vm.mem, arg)
+		if err != nil {
+			return executionError(inst.line, err)
+		}
+		call.Args[i] = v
+		call.slots[i] = -1
+		if arg.typ != VAL_CONST {
+			// the address is valid, the value was just read from it
+			call.slots[i], _ = addressFromMem(vm.mem, arg)
+		}
+	}
+
+	handler := vm.syscalls[s.name]
+	if handler == nil {
+		return executionError(inst.line, formatError("[sys]", I18N_ERR_SYS_NOT_FOUND, s.name))
+	}
+	if err := handler.Call(call); err != nil {
+		return executionError(inst.line, err)
+	}
+	return nil
+}
+
+// sysRand the source of `sys rand`, shared by the programs executed at the same time
+var sysRand = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// randSyscall `sys rand $target min max` a random number between min and max, both included
+type randSyscall struct{}
+
+func (randSyscall) Arity() int {
+	return 3
+}
+
+func (randSyscall) Call(call *SysCall) error {
+	min, max := call.Args[1], call.Args[2]
+	// the size of the range overflows when it is too big
+	if max < min || max-min+1 <= 0 {
+		return formatError("[sys]", I18N_EXEC_ERR_SYS_RANGE, max)
+	}
+	sysRand.Lock()
+	v := min + sysRand.Int63n(max-min+1)
+	sysRand.Unlock()
+	return call.Set(0, v)
+}
+
+// timeSyscall `sys time $target` the milliseconds since 1970
+type timeSyscall struct{}
+
+func (timeSyscall) Arity() int {
+	return 1
+}
+
+func (timeSyscall) Call(call *SysCall) error {
+	return call.Set(0, time.Now().UnixNano()/int64(time.Millisecond))
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// pixelSyscall `sys pixel x y color` paints a pixel of a buffer owned by the test
+type pixelSyscall struct {
+	buffer map[[2]int64]int64
+}
+
+func (pixelSyscall) Arity() int {
+	return 3
+}
+
+func (p pixelSyscall) Call(call *SysCall) error {
+	p.buffer[[2]int64{call.Args[0], call.Args[1]}] = call.Args[2]
+	return nil
+}
+
+// sumSyscall `sys sum $target values...` any amount of arguments
+type sumSyscall struct{}
+
+func (sumSyscall) Arity() int {
+	return -1
+}
+
+func (sumSyscall) Call(call *SysCall) error {
+	var sum int64
+	for _, v := range call.Args[1:] {
+		sum += v
+	}
+	return call.Set(0, sum)
+}
+
+// hasPlotInst `plot x y` a new instruction that compiles into `sys pixel x y 1`
+func hasPlotInst(kw Keywords, tokens []string) (*Instruction, error) {
+	if tokens[0] != "plot" {
+		return nil, nil
+	}
+	if len(tokens) != 3 {
+		return nil, formatError("plot", I18N_ERR_MISSING_PARAM, tokens)
+	}
+	x, y := hasValue(tokens[1]), hasValue(tokens[2])
+	if x == nil || y == nil {
+		return nil, formatError("plot", I18N_ERR_READ_EXPECT_VALUE, tokens[1:])
+	}
+	return newSysInst("pixel", []InstValue{*x, *y, {typ: VAL_CONST, val: 1}})
+}
+
+func registerTestSyscalls(t *testing.T, pixel pixelSyscall) {
+	instructions := INSTRUCTIONS
+	t.Cleanup(func() {
+		delete(SYSCALLS, "pixel")
+		delete(SYSCALLS, "sum")
+		INSTRUCTIONS = instructions
+	})
+	registerSyscall("pixel", pixel)
+	registerSyscall("sum", sumSyscall{})
+	registerInstruction(hasPlotInst)
+}
+
+func TestSyscall(t *testing.T) {
+	pixel := pixelSyscall{buffer: map[[2]int64]int64{}}
+	registerTestSyscalls(t, pixel)
+
+	code := "$0 = 3\n$3 = 7\nsys sum $1 $0 &0 10 # the sum\nplot $1 2\nsys pixel 0 0 $3\nsys rand $2 -2 2\nsys time $3\nwrite $1\n"
+	prog, err := compile(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, engine := range []string{ENGINE_SWITCH, ENGINE_CLOSURE} {
+		results, err := executeWith(engine, *prog, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var received []string
+		for _, r := range results {
+			received = append(received, r.ToString())
+		}
+		if expected := []string{"$ [ 1 ] 20"}; !reflect.DeepEqual(received, expected) {
+			t.Errorf("%s\nExpected: '%v'\nReceived: '%v'", engine, expected, received)
+		}
+	}
+	if !reflect.DeepEqual(pixel.buffer, map[[2]int64]int64{{20, 2}: 1, {0, 0}: 7}) {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", map[[2]int64]int64{{20, 2}: 1, {0, 0}: 7}, pixel.buffer)
+	}
+
+	vm := newVM(*prog, nil)
+	if err := vm.run(); err != nil {
+		t.Fatal(err)
+	}
+	if vm.mem[2] < -2 || vm.mem[2] > 2 {
+		t.Errorf("$2 = %d isn't between -2 and 2", vm.mem[2])
+	}
+	if vm.mem[3] <= 0 {
+		t.Errorf("$3 = %d isn't a time", vm.mem[3])
+	}
+
+	// a VM can replace the handlers of the registered syscalls without changing the other VMs
+	vm = newVM(*prog, nil)
+	vm.registerSyscall("rand", sumSyscall{})
+	vm.registerSyscall("time", sumSyscall{})
+	if err := vm.run(); err != nil {
+		t.Fatal(err)
+	}
+	checkValues(t, []testValue{{"$2", 0, vm.mem[2]}, {"$3", 0, vm.mem[3]}})
+	if handler := newVM(*prog, nil).syscalls["rand"]; handler != (randSyscall{}) {
+		t.Errorf("\nExpected: '%T'\nReceived: '%T'", randSyscall{}, handler)
+	}
+	if handler := SYSCALLS["time"]; handler != (timeSyscall{}) {
+		t.Errorf("\nExpected: '%T'\nReceived: '%T'", timeSyscall{}, handler)
+	}
+
+	decoded, err := decodeProgram(encodeProgram(*prog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.instructions, prog.instructions) {
+		t.Errorf("\nExpected: %+v\nReceived: %+v", prog.instructions, decoded.instructions)
+	}
+	if text := instructionText(prog.instructions[3]); text != "sys pixel $1 2 1" {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", "sys pixel $1 2 1", text)
+	}
+}
+
+func TestSyscallErrors(t *testing.T) {
+	registerTestSyscalls(t, pixelSyscall{buffer: map[[2]int64]int64{}})
+
+	compileErrors := map[string]string{
+		"sys":                "missing a parameter",
+		"sys nope $0":        "syscall not found: nope",
+		"sys rand $0 1":      "wrong number of arguments, expecting: 3",
+		"sys rand $0 1 x":    "expecting a value, but received: x",
+		"sys 1":              "expecting the name of a syscall",
+		"#lang pt\nsis time": "wrong number of arguments, expecting: 1",
+	}
+	for code, expected := range compileErrors {
+		if _, err := compile(code); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%s\nExpected: '%v'\nReceived: '%v'", code, expected, err)
+		}
+	}
+
+	execErrors := map[string]string{
+		"sys time 5":          "[Execution error: line 1] <[sys]> the syscall can't write to a constant argument: 0.",
+		"sys rand $0 3 1":     "[Execution error: line 1] <[sys]> the maximum is smaller than the minimum or the range is too big: 1.",
+		"$0 = -1\nsys sum &0": "[Execution error: line 2] <[memory]> invalid memory access: 0.",
+	}
+	for code, expected := range execErrors {
+		prog, err := compile(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := execute(*prog, nil); err == nil || err.Error() != expected {
+			t.Errorf("%s\nExpected: '%v'\nReceived: '%v'", code, expected, err)
+		}
+	}
+}
+
+func TestSyscallHistory(t *testing.T) {
+	registerTestSyscalls(t, pixelSyscall{buffer: map[[2]int64]int64{}})
+	prog, err := compile("$0 = 1\n$1 = 2\nsys sum $0 $0 $1 $0\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm := newVM(*prog, nil)
+	vm.history = &History{}
+	if err := vm.run(); err != nil {
+		t.Fatal(err)
+	}
+	step, _, old := vm.lastChange(0)
+	checkValues(t, []testValue{{"$0", 4, vm.mem[0]}, {"step", 3, int64(step)}, {"old", 1, old}})
+	vm.back()
+	checkValues(t, []testValue{{"$0", 1, vm.mem[0]}, {"$1", 2, vm.mem[1]}})
+	if warnings := checkUninitialized(*prog); len(warnings) != 0 {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", 0, len(warnings))
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const C_HEADER = `/* Code generated by fasm transpile --c. DO NOT EDIT. */
+#include <errno.h>
+#include <inttypes.h>
+#include <stdint.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+#define MEMORY_SIZE %d
+
+static int64_t mem[MEMORY_SIZE];
+static int64_t *input = NULL;
+static size_t input_len = 0;
+static size_t rc = 0;
+
+static void fail(int line, const char *typ, const char *message, const char *problem) {
+	printf(%s, line, typ, message, problem);
+	exit(1);
+}
+
+static inline int64_t ref(int line, int slot) {
+	if (mem[slot] < 0 || mem[slot] >= MEMORY_SIZE) {
+		char problem[16];
+		snprintf(problem, sizeof problem, "%%d", slot);
+		fail(line, "[memory]", %s, problem);
+	}
+	return mem[slot];
+}
+
+/* the arithmetic wraps around like in Go */
+static inline int64_t add(int64_t a, int64_t b) { return (int64_t)((uint64_t)a + (uint64_t)b); }
+static inline int64_t sub(int64_t a, int64_t b) { return (int64_t)((uint64_t)a - (uint64_t)b); }
+static inline int64_t mul(int64_t a, int64_t b) { return (int64_t)((uint64_t)a * (uint64_t)b); }
+static inline int64_t divide(int line, int64_t a, int64_t b) {
+	if (b == 0) {
+		fail(line, "[op]", %s, "0");
+	}
+	if (b == -1) {
+		return (int64_t)(0 - (uint64_t)a);
+	}
+	return a / b;
+}
+
+/* parse a number with the same rules of strconv.ParseInt */
+static int parse_int64(const char *s, int64_t *out) {
+	const char *digits = s;
+	char *end;
+	if (*digits == '+' || *digits == '-') {
+		digits++;
+	}
+	if (*digits == '\0' || strspn(digits, "0123456789") != strlen(digits)) {
+		return 0;
+	}
+	errno = 0;
+	*out = strtoll(s, &end, 10);
+	return errno == 0 && *end == '\0';
+}
+
+static void read_input(const char *filepath) {
+	FILE *f = fopen(filepath, "rb");
+	if (f == NULL) {
+		printf("open %%s: %%s\n", filepath, strerror(errno));
+		exit(1);
+	}
+	size_t size = 0, capacity = 1024;
+	char *dat = malloc(capacity + 1);
+	size_t n;
+	while ((n = fread(dat + size, 1, capacity - size, f)) > 0) {
+		size += n;
+		if (size == capacity) {
+			capacity *= 2;
+			dat = realloc(dat, capacity + 1);
+		}
+	}
+	fclose(f);
+	dat[size] = '\0';
+
+	int line = 1;
+	char *start = dat;
+	for (;;) {
+		char *nl = strchr(start, '\n');
+		if (nl != NULL) {
+			*nl = '\0';
+		}
+		char *v = start;
+		while (*v == ' ' || *v == '\t' || *v == '\r' || *v == '\v' || *v == '\f') {
+			v++;
+		}
+		char *e = v + strlen(v);
+		while (e > v && (e[-1] == ' ' || e[-1] == '\t' || e[-1] == '\r' || e[-1] == '\v' || e[-1] == '\f')) {
+			*--e = '\0';
+		}
+		input = realloc(input, (input_len + 1) * sizeof *input);
+		if (!parse_int64(v, &input[input_len])) {
+			printf(%s, filepath, line, v);
+			exit(1);
+		}
+		input_len++;
+		if (nl == NULL) {
+			break;
+		}
+		start = nl + 1;
+		line++;
+	}
+	free(dat);
+}
+
+int main(int argc, char **argv) {
+	if (argc > 1) {
+		read_input(argv[1]);
+	}
+	(void)rc;
+`
+
+var C_OPERATOR_FUNC = map[int]string{
+	OP_ADD: "add",
+	OP_SUB: "sub",
+	OP_MUL: "mul",
+	OP_DIV: "divide",
+}
+
+// cString a C string literal, the text is kept as UTF-8
+func cString(text string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`).Replace(text) + `"`
+}
+
+// cValue the C expression to read a value
+func cValue(line int, v InstValue) string {
+	switch v.typ {
+	case VAL_CONST:
+		if v.val == math.MinInt64 {
+			return "INT64_MIN"
+		}
+		return fmt.Sprintf("INT64_C(%d)", v.val)
+	case VAL_VAR:
+		return fmt.Sprintf("mem[%d]", v.val)
+	case VAL_REF:
+		return fmt.Sprintf("mem[ref(%d, %d)]", line, v.val)
+	}
+	panic("IMPOSSIBLE")
+}
+
+// cCondition the C statements that evaluate a condition into `c`, every comparison is evaluated
+// like `evaluateCondition` does
+func cCondition(sb *strings.Builder, line int, cond []interface{}) {
+	for j := 0; j < len(cond); j += 4 {
+		sb.WriteString(fmt.Sprintf("\t\tint64_t a%d = %s;\n", j, cValue(line, cond[j].(InstValue))))
+		sb.WriteString(fmt.Sprintf("\t\tint64_t b%d = %s;\n", j, cValue(line, cond[j+2].(InstValue))))
+		cmp := fmt.Sprintf("a%d %s b%d", j, COMPARISON_TEXT[cond[j+1].(int64)], j)
+		if j == 0 {
+			sb.WriteString(fmt.Sprintf("\t\tint c = %s;\n", cmp))
+		} else {
+			sb.WriteString(fmt.Sprintf("\t\tint r%d = %s;\n", j, cmp))
+			sb.WriteString(fmt.Sprintf("\t\tc = c %s r%d;\n", LOGIC_OPERATOR_TEXT[cond[j-1].(int64)], j))
+		}
+	}
+}
+
+// transpileC generate a portable C99 program with the same memory model and output of `execute`
+func transpileC(prog Program) string {
+	var sb strings.Builder
+	targets := jumpTargets(prog)
+	label := func(i int) string {
+		return fmt.Sprintf("L%d", i)
+	}
+	errTemplate := strings.Replace(I18N_EXEC_ERR_TEMPLATE, "%v", "<%s> %s: %s", 1) + "\n"
+
+	sb.WriteString(fmt.Sprintf(C_HEADER, MEMORY_SIZE, cString(errTemplate), cString(I18N_EXEC_ERR_INVALID_MEMORY_ACCESS), cString(I18N_EXEC_ERR_DIVISION_BY_ZERO), cString(i18nTemplate(I18N_INPUT_ERR_TEMPLATE, 3))))
+	for i, inst := range prog.instructions {
+		if targets[i] {
+			sb.WriteString(label(i) + ":;\n")
+		}
+		sb.WriteString(fmt.Sprintf("\t/* %d: %s */\n", inst.line, instructionText(inst)))
+
+		switch inst.typ {
+		case INST_OP:
+			op := inst.val.(Operation)
+			sb.WriteString("\t{\n")
+			sb.WriteString(fmt.Sprintf("\t\tint64_t v1 = %s;\n", cValue(inst.line, op.v1)))
+			res := "v1"
+			if op.op != OP_UNI {
+				sb.WriteString(fmt.Sprintf("\t\tint64_t v2 = %s;\n", cValue(inst.line, op.v2)))
+				res = fmt.Sprintf("%s(v1, v2)", C_OPERATOR_FUNC[op.op])
+				if op.op == OP_DIV {
+					res = fmt.Sprintf("%s(%d, v1, v2)", C_OPERATOR_FUNC[op.op], inst.line)
+				}
+			}
+			addr := fmt.Sprintf("%d", op.v.val)
+			if op.v.typ == VAL_REF {
+				sb.WriteString(fmt.Sprintf("\t\tint64_t a = ref(%d, %d);\n", inst.line, op.v.val))
+				addr = "a"
+			}
+			sb.WriteString(fmt.Sprintf("\t\tmem[%s] = %s;\n", addr, res))
+			sb.WriteString("\t}\n")
+			break
+		case INST_TO:
+			to := inst.val.(IfInst)
+			target := label(prog.labels[to.target])
+			if to.moveIf == nil {
+				sb.WriteString(fmt.Sprintf("\tgoto %s;\n", target))
+				break
+			}
+			sb.WriteString("\t{\n")
+			cCondition(&sb, inst.line, to.moveIf)
+			sb.WriteString(fmt.Sprintf("\t\tif (c) {\n\t\t\tgoto %s;\n\t\t}\n", target))
+			sb.WriteString("\t}\n")
+			break
+		case INST_WRITE:
+			v := inst.val.(InstValue)
+			switch v.typ {
+			case VAL_CONST:
+				sb.WriteString(fmt.Sprintf("\tputs(%s);\n", cString(WriteResult{val: v}.ToString())))
+				break
+			case VAL_VAR:
+				sb.WriteString(fmt.Sprintf("\tprintf(\"$ [ %d ] %%\" PRId64 \"\\n\", mem[%d]);\n", v.val, v.val))
+				break
+			case VAL_REF:
+				sb.WriteString("\t{\n")
+				sb.WriteString(fmt.Sprintf("\t\tint64_t a = ref(%d, %d);\n", inst.line, v.val))
+				sb.WriteString(fmt.Sprintf("\t\tprintf(\"$ [ %d -> %%\" PRId64 \" ] %%\" PRId64 \"\\n\", a, mem[a]);\n", v.val))
+				sb.WriteString("\t}\n")
+				break
+			}
+			break
+		case INST_READ:
+			r := inst.val.(ReadInst)
+			sb.WriteString("\tif (rc < input_len) {\n")
+			if r.target.typ == VAL_REF {
+				sb.WriteString(fmt.Sprintf("\t\tmem[ref(%d, %d)] = input[rc];\n", inst.line, r.target.val))
+			} else {
+				sb.WriteString(fmt.Sprintf("\t\tmem[%d] = input[rc];\n", r.target.val))
+			}
+			sb.WriteString("\t\trc++;\n")
+			sb.WriteString("\t} else {\n")
+			if r.elseLabel == "" {
+				sb.WriteString(fmt.Sprintf("\t\tfail(%d, \"[read]\", %s, %s);\n", inst.line, cString(I18N_ERR_READ_NOTHING), cString(I18N_ERR_READ_NO_ELSE_LABEL)))
+			} else {
+				sb.WriteString(fmt.Sprintf("\t\tgoto %s;\n", label(prog.labels[r.elseLabel])))
+			}
+			sb.WriteString("\t}\n")
+			break
+		case INST_ASSERT:
+			a := inst.val.(AssertInst)
+			parts, args := assertProblem(a)
+			size := 1
+			literals := make([]string, len(parts))
+			for i, part := range parts {
+				literals[i] = cString(strings.ReplaceAll(part, "%", "%%"))
+				size += len(part) + 20
+			}
+			sb.WriteString("\t{\n")
+			cCondition(&sb, inst.line, a.cond)
+			sb.WriteString("\t\tif (!c) {\n")
+			sb.WriteString(fmt.Sprintf("\t\t\tchar problem[%d];\n", size))
+			format := strings.Join(literals, ` "%" PRId64 `)
+			if len(args) > 0 {
+				format += ", " + strings.Join(args, ", ")
+			}
+			sb.WriteString(fmt.Sprintf("\t\t\tsnprintf(problem, sizeof problem, %s);\n", format))
+			sb.WriteString(fmt.Sprintf("\t\t\tfail(%d, \"[assert]\", %s, problem);\n", inst.line, cString(I18N_EXEC_ERR_ASSERT)))
+			sb.WriteString("\t\t}\n")
+			sb.WriteString("\t}\n")
+			break
+		}
+	}
+	if targets[len(prog.instructions)] {
+		sb.WriteString(label(len(prog.instructions)) + ":;\n")
+	}
+	sb.WriteString("\treturn 0;\n}\n")
+
+	return sb.String()
+}
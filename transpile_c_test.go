@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTranspileC(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles every generated program")
+	}
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("C compiler not found")
+	}
+
+	dir := t.TempDir()
+	for _, c := range transpileCases(t) {
+		prog, expected := interpreterOutput(t, c)
+
+		src := filepath.Join(dir, c.name+".c")
+		bin := filepath.Join(dir, c.name+".bin")
+		if err := os.WriteFile(src, []byte(transpileC(*prog)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if out, err := exec.Command(cc, "-std=c99", "-pedantic", "-Wall", "-Werror", "-o", bin, src).CombinedOutput(); err != nil {
+			t.Errorf("%v: %v\n%s", c.name, err, out)
+			continue
+		}
+
+		received := runGenerated(t, bin, c)
+		if received != expected {
+			t.Errorf("%v\nExpected: '%v'\nReceived: '%v'", c.name, expected, received)
+		}
+
+		// the examples also have the golden output
+		golden, err := os.ReadFile(c.source + ".out")
+		if err != nil {
+			continue
+		}
+		lines := strings.Split(strings.TrimSpace(received), "\n")
+		for i, o := range strings.Split(string(golden), "\n") {
+			if i >= len(lines) || strings.TrimSpace(o) != lines[i] {
+				t.Errorf("%v\nExpected: '%v'\nReceived: '%v'", c.name, string(golden), received)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"strings"
+)
+
+// verbArg an argument printed as its own verb, used to get a translated template with the verbs kept
+type verbArg struct{}
+
+func (verbArg) Format(f fmt.State, c rune) {
+	fmt.Fprintf(f, "%%%c", c)
+}
+
+// i18nTemplate the translated template of a message with `n` verbs, to be embedded in generated code
+func i18nTemplate(key string, n int) string {
+	args := make([]interface{}, n)
+	for i := range args {
+		args[i] = verbArg{}
+	}
+	return i18n.Sprintf(key, args...)
+}
+
+// jumpTargets the instruction indexes that are the target of a jump
+func jumpTargets(prog Program) map[int]bool {
+	targets := make(map[int]bool)
+	for _, inst := range prog.instructions {
+		switch inst.typ {
+		case INST_TO:
+			targets[prog.labels[inst.val.(IfInst).target]] = true
+			break
+		case INST_READ:
+			if label := inst.val.(ReadInst).elseLabel; label != "" {
+				targets[prog.labels[label]] = true
+			}
+			break
+		}
+	}
+	return targets
+}
+
+const GO_HEADER = `// Code generated by fasm transpile --go. DO NOT EDIT.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const MEMORY_SIZE = %d
+
+var mem [MEMORY_SIZE]int64
+var input []int64
+var rc int
+
+func fail(line int, typ string, message string, problem interface{}) {
+	fmt.Println(fmt.Sprintf(%q, line, fmt.Sprintf("<%%v> %%v: %%v", typ, message, problem)))
+	os.Exit(1)
+}
+
+func ref(line int, slot int) int64 {
+	if mem[slot] < 0 || mem[slot] >= MEMORY_SIZE {
+		fail(line, "[memory]", %q, slot)
+	}
+	return mem[slot]
+}
+
+func add(a, b int64) int64 { return a + b }
+func sub(a, b int64) int64 { return a - b }
+func mul(a, b int64) int64 { return a * b }
+func div(line int, a, b int64) int64 {
+	if b == 0 {
+		fail(line, "[op]", %q, 0)
+	}
+	return a / b
+}
+
+func readInput(filepath string) {
+	dat, err := os.ReadFile(filepath)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	for i, v := range strings.Split(string(dat), "\n") {
+		v = strings.TrimSpace(v)
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			fmt.Printf(%q, filepath, i+1, v)
+			os.Exit(1)
+		}
+		input = append(input, n)
+	}
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		readInput(os.Args[1])
+	}
+`
+
+var GO_OPERATOR_FUNC = map[int]string{
+	OP_ADD: "add",
+	OP_SUB: "sub",
+	OP_MUL: "mul",
+	OP_DIV: "div",
+}
+
+// goValue the Go expression to read a value
+func goValue(line int, v InstValue) string {
+	switch v.typ {
+	case VAL_CONST:
+		return fmt.Sprintf("int64(%d)", v.val)
+	case VAL_VAR:
+		return fmt.Sprintf("mem[%d]", v.val)
+	case VAL_REF:
+		return fmt.Sprintf("mem[ref(%d, %d)]", line, v.val)
+	}
+	panic("IMPOSSIBLE")
+}
+
+// goAddress the Go expression of the slot written by a value
+func goAddress(line int, v InstValue) string {
+	if v.typ == VAL_REF {
+		return fmt.Sprintf("ref(%d, %d)", line, v.val)
+	}
+	return fmt.Sprintf("%d", v.val)
+}
+
+// assertProblem the problem shown by a false assertion, like `assertionError`, split into the text parts
+// with the expressions of the values between them, the expressions are the same in Go and C
+func assertProblem(a AssertInst) ([]string, []string) {
+	message := a.message
+	if message == "" {
+		message = conditionText(a.cond)
+	}
+	parts := []string{message}
+	var args []string
+	seen := make(map[InstValue]bool)
+	for i, p := range a.cond {
+		if ifInstOrder(i) != IFO_VAL {
+			continue
+		}
+		v := p.(InstValue)
+		if v.typ == VAL_CONST || seen[v] {
+			continue
+		}
+		sep := ", "
+		if len(seen) == 0 {
+			sep = " ("
+		}
+		seen[v] = true
+		if v.typ == VAL_VAR {
+			parts[len(parts)-1] += fmt.Sprintf("%s$%d = ", sep, v.val)
+			parts = append(parts, "")
+			args = append(args, fmt.Sprintf("mem[%d]", v.val))
+		} else {
+			parts[len(parts)-1] += fmt.Sprintf("%s&%d -> ", sep, v.val)
+			parts = append(parts, " = ", "")
+			args = append(args, fmt.Sprintf("mem[%d]", v.val), fmt.Sprintf("mem[mem[%d]]", v.val))
+		}
+	}
+	if len(args) > 0 {
+		parts[len(parts)-1] += ")"
+	}
+	return parts, args
+}
+
+// goCondition the Go statements that evaluate a condition into `c`, every comparison is evaluated
+// like `evaluateCondition` does
+func goCondition(sb *strings.Builder, line int, cond []interface{}) {
+	for j := 0; j < len(cond); j += 4 {
+		sb.WriteString(fmt.Sprintf("\t\ta%d, b%d := %s, %s\n", j, j, goValue(line, cond[j].(InstValue)), goValue(line, cond[j+2].(InstValue))))
+		cmp := fmt.Sprintf("a%d %s b%d", j, COMPARISON_TEXT[cond[j+1].(int64)], j)
+		if j == 0 {
+			sb.WriteString(fmt.Sprintf("\t\tc := %s\n", cmp))
+		} else {
+			sb.WriteString(fmt.Sprintf("\t\tr%d := %s\n", j, cmp))
+			sb.WriteString(fmt.Sprintf("\t\tc = c %s r%d\n", LOGIC_OPERATOR_TEXT[cond[j-1].(int64)], j))
+		}
+	}
+}
+
+// transpileGo generate a standalone Go program with the same memory model and output of `execute`,
+// every value is read in the same order of the interpreter so the same error is reported
+func transpileGo(prog Program) string {
+	var sb strings.Builder
+	targets := jumpTargets(prog)
+	label := func(i int) string {
+		return fmt.Sprintf("L%d", i)
+	}
+
+	sb.WriteString(fmt.Sprintf(GO_HEADER, MEMORY_SIZE, I18N_EXEC_ERR_TEMPLATE, I18N_EXEC_ERR_INVALID_MEMORY_ACCESS, I18N_EXEC_ERR_DIVISION_BY_ZERO, i18nTemplate(I18N_INPUT_ERR_TEMPLATE, 3)))
+	for i, inst := range prog.instructions {
+		if targets[i] {
+			sb.WriteString(label(i) + ":\n")
+		}
+		sb.WriteString(fmt.Sprintf("\t// %d: %s\n", inst.line, instructionText(inst)))
+
+		switch inst.typ {
+		case INST_OP:
+			op := inst.val.(Operation)
+			sb.WriteString("\t{\n")
+			if op.op == OP_UNI {
+				sb.WriteString(fmt.Sprintf("\t\tv1 := %s\n", goValue(inst.line, op.v1)))
+				sb.WriteString(fmt.Sprintf("\t\tmem[%s] = v1\n", goAddress(inst.line, op.v)))
+			} else {
+				sb.WriteString(fmt.Sprintf("\t\tv1, v2 := %s, %s\n", goValue(inst.line, op.v1), goValue(inst.line, op.v2)))
+				res := fmt.Sprintf("%s(v1, v2)", GO_OPERATOR_FUNC[op.op])
+				if op.op == OP_DIV {
+					res = fmt.Sprintf("%s(%d, v1, v2)", GO_OPERATOR_FUNC[op.op], inst.line)
+				}
+				sb.WriteString(fmt.Sprintf("\t\tmem[%s] = %s\n", goAddress(inst.line, op.v), res))
+			}
+			sb.WriteString("\t}\n")
+			break
+		case INST_TO:
+			to := inst.val.(IfInst)
+			target := label(prog.labels[to.target])
+			if to.moveIf == nil {
+				sb.WriteString(fmt.Sprintf("\tgoto %s\n", target))
+				break
+			}
+			sb.WriteString("\t{\n")
+			goCondition(&sb, inst.line, to.moveIf)
+			sb.WriteString(fmt.Sprintf("\t\tif c {\n\t\t\tgoto %s\n\t\t}\n", target))
+			sb.WriteString("\t}\n")
+			break
+		case INST_WRITE:
+			v := inst.val.(InstValue)
+			switch v.typ {
+			case VAL_CONST:
+				sb.WriteString(fmt.Sprintf("\tfmt.Println(%q)\n", WriteResult{val: v}.ToString()))
+				break
+			case VAL_VAR:
+				sb.WriteString(fmt.Sprintf("\tfmt.Println(\"$ [ %d ]\", mem[%d])\n", v.val, v.val))
+				break
+			case VAL_REF:
+				sb.WriteString("\t{\n")
+				sb.WriteString(fmt.Sprintf("\t\ta := ref(%d, %d)\n", inst.line, v.val))
+				sb.WriteString(fmt.Sprintf("\t\tfmt.Println(\"$ [ %d ->\", a, \"]\", mem[a])\n", v.val))
+				sb.WriteString("\t}\n")
+				break
+			}
+			break
+		case INST_READ:
+			r := inst.val.(ReadInst)
+			sb.WriteString("\tif rc < len(input) {\n")
+			sb.WriteString(fmt.Sprintf("\t\tmem[%s] = input[rc]\n", goAddress(inst.line, r.target)))
+			sb.WriteString("\t\trc++\n")
+			sb.WriteString("\t} else {\n")
+			if r.elseLabel == "" {
+				sb.WriteString(fmt.Sprintf("\t\tfail(%d, \"[read]\", %q, %q)\n", inst.line, I18N_ERR_READ_NOTHING, I18N_ERR_READ_NO_ELSE_LABEL))
+			} else {
+				sb.WriteString(fmt.Sprintf("\t\tgoto %s\n", label(prog.labels[r.elseLabel])))
+			}
+			sb.WriteString("\t}\n")
+			break
+		case INST_ASSERT:
+			a := inst.val.(AssertInst)
+			parts, args := assertProblem(a)
+			for i := range parts {
+				parts[i] = strings.ReplaceAll(parts[i], "%", "%%")
+			}
+			problem := fmt.Sprintf("%q", strings.Join(parts, "%d"))
+			if len(args) > 0 {
+				problem = fmt.Sprintf("fmt.Sprintf(%s, %s)", problem, strings.Join(args, ", "))
+			}
+			sb.WriteString("\t{\n")
+			goCondition(&sb, inst.line, a.cond)
+			sb.WriteString(fmt.Sprintf("\t\tif !c {\n\t\t\tfail(%d, \"[assert]\", %q, %s)\n\t\t}\n", inst.line, I18N_EXEC_ERR_ASSERT, problem))
+			sb.WriteString("\t}\n")
+			break
+		}
+	}
+	if targets[len(prog.instructions)] {
+		sb.WriteString(label(len(prog.instructions)) + ":\n\treturn\n")
+	}
+	sb.WriteString("}\n")
+
+	code, err := format.Source([]byte(sb.String()))
+	if err != nil {
+		panic(err)
+	}
+	return string(code)
+}
+
+// runTranspile the `transpile` command, generate the code of a program in another language
+func runTranspile(args []string) int {
+	flags := flag.NewFlagSet("transpile", flag.ExitOnError)
+	toGo := flags.Bool("go", false, i18n.Sprintf(I18N_TRANSPILE_FLAG_GO))
+	toC := flags.Bool("c", false, i18n.Sprintf(I18N_TRANSPILE_FLAG_C))
+	output := flags.String("o", "", i18n.Sprintf(I18N_TRANSPILE_FLAG_OUTPUT))
+	optimized := flags.Bool("O", false, i18n.Sprintf(I18N_FLAG_OPTIMIZE))
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		i18n.Println(I18N_ERR_PROG_TOO_MANY_PARMS)
+		return 1
+	}
+	if !isProgramFile(flags.Arg(0)) {
+		i18n.Println(I18N_ERR_PROG_NEED_ASM_EXT)
+		return 1
+	}
+	if *toGo == *toC {
+		i18n.Println(I18N_TRANSPILE_ERR_NO_TARGET)
+		return 1
+	}
+
+	prog, err := loadProgram(flags.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if *optimized {
+		*prog = optimize(*prog)
+	}
+	for _, inst := range prog.instructions {
+		if inst.typ == INST_SYS {
+			fmt.Println(compilationError(inst.line, formatError("sys", I18N_ERR_SYS_NO_TRANSPILE, inst.val.(SysInst).name)))
+			return 1
+		}
+	}
+
+	var code string
+	if *toGo {
+		code = transpileGo(*prog)
+	} else {
+		code = transpileC(*prog)
+	}
+	if *output == "" {
+		fmt.Print(code)
+		return 0
+	}
+	if err := os.WriteFile(*output, []byte(code), 0644); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// transpileCase a program to run on the interpreter and on the generated code
+type transpileCase struct {
+	name   string
+	source string
+	input  string
+}
+
+// transpileCases every example plus programs that stop with an execution error
+func transpileCases(t *testing.T) []transpileCase {
+	items, err := os.ReadDir(EXAMPLE_FILENAME)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cases []transpileCase
+	for _, item := range items {
+		if strings.HasSuffix(item.Name(), ".asm") {
+			input := path.Join(EXAMPLE_FILENAME, item.Name()+".in")
+			if _, err := os.Stat(input); err != nil {
+				input = ""
+			}
+			cases = append(cases, transpileCase{item.Name(), path.Join(EXAMPLE_FILENAME, item.Name()), input})
+		}
+	}
+
+	dir := t.TempDir()
+	errors := map[string]string{
+		"ref_read.asm":    "$0 = 2000\nwrite 1\nwrite &0\n",
+		"ref_write.asm":   "$0 = -1\n&0 = 1 + 2\n",
+		"read.asm":        "read $1\n",
+		"cond.asm":        "$0 = 5000\nto end if 1 == 2 || &0 > 1\nend:\n",
+		"assert.asm":      "$0 = 3\n$1 = 0\nassert $0 > 1 \"three is big\"\nassert $0 < 3 && &1 == 3 \"100% \\\"sure\\\" # é\"\n",
+		"assert_cond.asm": "$0 = 1\nassert $0 == 2 || 1 > 2\n",
+		"div.asm":         "$0 = 7\n$1 = $0 / 2\nwrite $1\n$2 = $0 / $3\n",
+		"div_ref.asm":     "$0 = 5\n&0 = 1 / &0\n",
+	}
+	for name, code := range errors {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(code), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cases = append(cases, transpileCase{name, filepath.Join(dir, name), ""})
+	}
+	return cases
+}
+
+// interpreterOutput what `fasm run` prints for the program
+func interpreterOutput(t *testing.T, c transpileCase) (*Program, string) {
+	prog, err := loadProgram(c.source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input, err := readInput(c.input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sb strings.Builder
+	res, err := execute(*prog, input)
+	for _, r := range res {
+		sb.WriteString(r.ToString() + "\n")
+	}
+	if err != nil {
+		sb.WriteString(err.Error() + "\n")
+	}
+	return prog, sb.String()
+}
+
+// runGenerated execute the binary generated for a case
+func runGenerated(t *testing.T, bin string, c transpileCase) string {
+	var args []string
+	if c.input != "" {
+		args = append(args, c.input)
+	}
+	out, err := exec.Command(bin, args...).Output()
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestTranspileGo(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds every generated program")
+	}
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not found")
+	}
+
+	dir := t.TempDir()
+	for _, c := range transpileCases(t) {
+		prog, expected := interpreterOutput(t, c)
+
+		src := filepath.Join(dir, c.name+".go")
+		bin := filepath.Join(dir, c.name+".bin")
+		if err := os.WriteFile(src, []byte(transpileGo(*prog)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cmd := exec.Command(gobin, "build", "-o", bin, src)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GO111MODULE=off", "GOFLAGS=")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("%v: %v\n%s", c.name, err, out)
+			continue
+		}
+
+		if received := runGenerated(t, bin, c); received != expected {
+			t.Errorf("%v\nExpected: '%v'\nReceived: '%v'", c.name, expected, received)
+		}
+	}
+}
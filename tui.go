@@ -0,0 +1,409 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+const (
+	// TUI_FLASH_STEPS for how many steps a memory cell stays highlighted after it changes
+	TUI_FLASH_STEPS = 3
+	// TUI_OUTPUT_LINES how many of the last results are shown
+	TUI_OUTPUT_LINES = 4
+
+	ANSI_RESET   = "\x1b[0m"
+	ANSI_REVERSE = "\x1b[7m"
+	ANSI_BOLD    = "\x1b[1m"
+	ANSI_FLASH   = "\x1b[30;43m"
+	ANSI_DIM     = "\x1b[2m"
+	ANSI_RED     = "\x1b[31m"
+)
+
+// TUI_SPEEDS the delays between the steps when playing, from the slowest
+var TUI_SPEEDS = []time.Duration{
+	time.Second,
+	500 * time.Millisecond,
+	200 * time.Millisecond,
+	100 * time.Millisecond,
+	50 * time.Millisecond,
+	20 * time.Millisecond,
+	5 * time.Millisecond,
+	time.Millisecond,
+}
+
+// Visualizer the state shown by `fasm tui`, every step goes through `VM.step`
+type Visualizer struct {
+	name  string
+	prog  Program
+	input []int64
+	// source the lines shown, byIndex when they are the instructions instead of the source file
+	source  []string
+	byIndex bool
+
+	vm  *VM
+	err error
+	// changed the step when each memory slot was last written, 0 is never
+	changed []int
+	prev    []int64
+
+	playing bool
+	speed   int
+	// memOffset the first row of the memory grid, cols the cells in each row of the last render
+	memOffset int
+	cols      int
+}
+
+func newVisualizer(name string, prog Program, source []string, input []int64) *Visualizer {
+	v := &Visualizer{name: name, prog: prog, input: input, source: source, speed: len(TUI_SPEEDS) / 2, cols: 1}
+	if source == nil {
+		v.byIndex = true
+		for _, inst := range prog.instructions {
+			v.source = append(v.source, instructionText(inst))
+		}
+	}
+	v.restart()
+	return v
+}
+
+// restart execute the program again from the beginning
+func (v *Visualizer) restart() {
+	v.vm = newVM(v.prog, v.input)
+	v.vm.history = newHistory(DEBUG_HISTORY_SIZE)
+	v.err = nil
+	v.changed = make([]int, MEMORY_SIZE)
+	v.prev = make([]int64, MEMORY_SIZE)
+}
+
+// finished if the program ended or stopped with an error
+func (v *Visualizer) finished() bool {
+	return v.err != nil || v.vm.done()
+}
+
+// advance execute one instruction and remember the memory cells it changed
+func (v *Visualizer) advance() {
+	if v.finished() {
+		v.playing = false
+		return
+	}
+	copy(v.prev, v.vm.mem)
+	v.err = v.vm.step()
+	for i, val := range v.vm.mem {
+		if val != v.prev[i] {
+			v.changed[i] = v.vm.steps
+		}
+	}
+	if v.finished() {
+		v.playing = false
+	}
+}
+
+// back undo the last instruction, the cells changed after it stop being highlighted
+func (v *Visualizer) back() {
+	if !v.vm.back() {
+		return
+	}
+	v.err = nil
+	for i := range v.changed {
+		if v.changed[i] > v.vm.steps {
+			v.changed[i] = 0
+		}
+	}
+}
+
+// currentLine the line of `source` of the next instruction, -1 when the program ended
+func (v *Visualizer) currentLine() int {
+	if v.vm.done() {
+		return -1
+	}
+	if v.byIndex {
+		return v.vm.pc
+	}
+	return v.vm.prog.instructions[v.vm.pc].line - 1
+}
+
+// fit cut or pad the text to have exactly `width` characters
+func fit(text string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	n := utf8.RuneCountInString(text)
+	if n > width {
+		runes := []rune(text)
+		return string(runes[:width])
+	}
+	return text + strings.Repeat(" ", width-n)
+}
+
+// sourcePane the lines of the source around the current one, which is highlighted
+func (v *Visualizer) sourcePane(width int, height int) []string {
+	current := v.currentLine()
+	first := 0
+	if current >= height/2 {
+		first = current - height/2
+	}
+	if first+height > len(v.source) {
+		first = len(v.source) - height
+	}
+	if first < 0 {
+		first = 0
+	}
+
+	rows := make([]string, height)
+	for i := range rows {
+		l := first + i
+		if l >= len(v.source) {
+			rows[i] = fit("", width)
+			continue
+		}
+		marker := "  "
+		if l == current {
+			marker = "> "
+		}
+		text := fit(fmt.Sprintf("%s%4d  %s", marker, l+1, strings.TrimRight(v.source[l], "\r")), width)
+		if l == current {
+			text = ANSI_REVERSE + text + ANSI_RESET
+		}
+		rows[i] = text
+	}
+	return rows
+}
+
+// memoryColumns how many cells fit in a row of the memory grid
+func memoryColumns(width int) int {
+	if cols := (width - 6) / 8; cols > 1 {
+		return cols
+	}
+	return 1
+}
+
+// memoryPane the grid of the memory starting at `memOffset`, the cells that changed recently are highlighted
+func (v *Visualizer) memoryPane(width int, height int) []string {
+	cols := memoryColumns(width)
+	v.cols = cols
+	rows := make([]string, height)
+	for r := range rows {
+		start := (v.memOffset + r) * cols
+		if start >= MEMORY_SIZE {
+			rows[r] = fit("", width)
+			continue
+		}
+		var sb strings.Builder
+		sb.WriteString(ANSI_DIM + fmt.Sprintf("%4d: ", start) + ANSI_RESET)
+		used := 6
+		for c := 0; c < cols && start+c < MEMORY_SIZE; c++ {
+			slot := start + c
+			cell := fit(fmt.Sprintf("%7d", v.vm.mem[slot]), 7) + " "
+			if v.changed[slot] > 0 && v.vm.steps-v.changed[slot] < TUI_FLASH_STEPS {
+				cell = ANSI_FLASH + cell[:7] + ANSI_RESET + " "
+			}
+			sb.WriteString(cell)
+			used += 8
+		}
+		sb.WriteString(fit("", width-used))
+		rows[r] = sb.String()
+	}
+	return rows
+}
+
+// inputLine the input queue, the next number to be read is between brackets
+func (v *Visualizer) inputLine(width int) string {
+	parts := make([]string, len(v.input))
+	for i, n := range v.input {
+		parts[i] = fmt.Sprintf("%d", n)
+		if i == v.vm.rc {
+			parts[i] = "[" + parts[i] + "]"
+		}
+	}
+	if v.vm.rc >= len(v.input) {
+		parts = append(parts, "[]")
+	}
+	return fit(i18n.Sprintf(I18N_TUI_INPUT, v.vm.rc, strings.Join(parts, " ")), width)
+}
+
+// render the whole screen with `width` columns and `height` lines
+func (v *Visualizer) render(width int, height int) string {
+	var sb strings.Builder
+	line := func(text string) {
+		sb.WriteString(text + "\x1b[K\r\n")
+	}
+
+	state := i18n.Sprintf(I18N_TUI_PAUSED)
+	if v.playing {
+		state = i18n.Sprintf(I18N_TUI_PLAYING)
+	} else if v.vm.done() && v.err == nil {
+		state = i18n.Sprintf(I18N_TUI_FINISHED)
+	}
+	speed := float64(time.Second) / float64(TUI_SPEEDS[v.speed])
+	line(ANSI_BOLD + fit(i18n.Sprintf(I18N_TUI_TITLE, v.name, state, speed, v.vm.steps, v.vm.pc), width) + ANSI_RESET)
+
+	body := height - TUI_OUTPUT_LINES - 5
+	if body < 3 {
+		body = 3
+	}
+	left := width * 11 / 20
+	source := v.sourcePane(left, body)
+	memory := v.memoryPane(width-left-1, body)
+	for i := 0; i < body; i++ {
+		line(source[i] + "│" + memory[i])
+	}
+
+	line(v.inputLine(width))
+	line(ANSI_BOLD + fit(i18n.Sprintf(I18N_TUI_OUTPUT), width) + ANSI_RESET)
+	// the error is always shown under the last results
+	results := v.vm.results
+	shown := TUI_OUTPUT_LINES
+	if v.err != nil {
+		shown--
+	}
+	if len(results) > shown {
+		results = results[len(results)-shown:]
+	}
+	for i := 0; i < TUI_OUTPUT_LINES; i++ {
+		if i < len(results) {
+			line(fit(results[i].ToString(), width))
+		} else if i == len(results) && v.err != nil {
+			line(ANSI_RED + fit(v.err.Error(), width) + ANSI_RESET)
+		} else {
+			line(fit("", width))
+		}
+	}
+	sb.WriteString(ANSI_DIM + fit(i18n.Sprintf(I18N_TUI_HELP), width) + ANSI_RESET + "\x1b[K")
+	return sb.String()
+}
+
+// key change the state for the pressed key, the result is false to quit
+func (v *Visualizer) key(k string) bool {
+	switch k {
+	case "q", "\x03", "\x1b":
+		return false
+	case " ", "p":
+		v.playing = !v.playing && !v.finished()
+		break
+	case "s", "n", "\x1b[C":
+		v.playing = false
+		v.advance()
+		break
+	case "b", "\x1b[D":
+		v.playing = false
+		v.back()
+		break
+	case "+", "=", "\x1b[A":
+		if v.speed < len(TUI_SPEEDS)-1 {
+			v.speed++
+		}
+		break
+	case "-", "\x1b[B":
+		if v.speed > 0 {
+			v.speed--
+		}
+		break
+	case "r":
+		v.playing = false
+		v.restart()
+		break
+	case "]", "\x1b[6~":
+		if (v.memOffset+1)*v.cols < MEMORY_SIZE {
+			v.memOffset++
+		}
+		break
+	case "[", "\x1b[5~":
+		if v.memOffset > 0 {
+			v.memOffset--
+		}
+		break
+	}
+	return true
+}
+
+// play draw the visualizer in the terminal until the user quits
+func (v *Visualizer) play() error {
+	fd := int(os.Stdin.Fd())
+	old, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, old)
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+	keys := make(chan string)
+	go func() {
+		buf := make([]byte, 8)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- string(buf[:n])
+		}
+	}()
+
+	for {
+		width, height, err := term.GetSize(int(os.Stdout.Fd()))
+		if err != nil {
+			width, height = 80, 24
+		}
+		fmt.Print("\x1b[H" + v.render(width, height))
+
+		var tick <-chan time.Time
+		if v.playing {
+			tick = time.After(TUI_SPEEDS[v.speed])
+		}
+		select {
+		case k, ok := <-keys:
+			if !ok || !v.key(k) {
+				return nil
+			}
+		case <-tick:
+			v.advance()
+		}
+	}
+}
+
+// runTUI the `tui` command, show the execution of a program step by step
+func runTUI(args []string) int {
+	flags := flag.NewFlagSet("tui", flag.ExitOnError)
+	flags.Parse(args)
+
+	if flags.NArg() == 0 || flags.NArg() > 2 {
+		i18n.Println(I18N_ERR_PROG_TOO_MANY_PARMS)
+		return 1
+	}
+	if !isProgramFile(flags.Arg(0)) {
+		i18n.Println(I18N_ERR_PROG_NEED_ASM_EXT)
+		return 1
+	}
+
+	prog, err := loadProgram(flags.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	var source []string
+	if strings.HasSuffix(flags.Arg(0), ".asm") {
+		code, err := read(flags.Arg(0))
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		source = strings.Split(code, "\n")
+	}
+	input, err := readInput(flags.Arg(1))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	if err := newVisualizer(flags.Arg(0), *prog, source, input).play(); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVisualizer(t *testing.T) {
+	code := "read $0\n$1 = $0 * 2\nwrite $1\nwrite 1\nwrite 2\nwrite 3\nread $0\n"
+	prog, err := compile(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := newVisualizer("double.asm", *prog, strings.Split(code, "\n"), []int64{21})
+
+	v.key("s")
+	v.key("s")
+	screen := v.render(80, 20)
+	if !strings.Contains(screen, ANSI_REVERSE+">    3  write $1") {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", ANSI_REVERSE+">    3  write $1", screen)
+	}
+	if !strings.Contains(screen, ANSI_FLASH+"     42"+ANSI_RESET) {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", ANSI_FLASH+"     42"+ANSI_RESET, screen)
+	}
+	if !strings.Contains(screen, "21 []") {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", "21 []", screen)
+	}
+
+	for i := 0; i < TUI_FLASH_STEPS; i++ {
+		v.key("s")
+	}
+	screen = v.render(80, 20)
+	if n := strings.Count(screen, ANSI_FLASH); n != 0 {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", 0, n)
+	}
+
+	v.key("s")
+	v.key("s")
+	screen = v.render(80, 20)
+	for _, line := range []string{"$ 3", "[Execution error: line 7]"} {
+		if !strings.Contains(screen, line) {
+			t.Errorf("\nExpected: '%v'\nReceived: '%v'", line, screen)
+		}
+	}
+	if n := strings.Count(screen, "$ [ 1 ] 42"); n != 0 {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", 0, n)
+	}
+	if v.key(" "); v.playing {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", false, v.playing)
+	}
+
+	v.key("b")
+	if v.err != nil {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", nil, v.err)
+	}
+	if v.vm.steps != 6 {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", 6, v.vm.steps)
+	}
+	if v.vm.pc != 6 {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", 6, v.vm.pc)
+	}
+
+	v.key("r")
+	if v.err != nil {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", nil, v.err)
+	}
+	if v.vm.steps != 0 {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", 0, v.vm.steps)
+	}
+	if v.vm.pc != 0 {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", 0, v.vm.pc)
+	}
+	if running := v.key("q"); running {
+		t.Errorf("\nExpected: '%v'\nReceived: '%v'", false, running)
+	}
+}